@@ -13,6 +13,7 @@ import (
 	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/config"
 	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/http/handlers/order"
 	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/http/handlers/trade"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/http/middleware"
 	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/storage/mysql"
 )
 
@@ -30,14 +31,34 @@ func main() {
 
 	// setup router
 	router := http.NewServeMux()
-	orderHandler := order.NewOrderHandler(storage)
+	orderHandler := order.NewOrderHandler(storage, cfg.Engine)
+	if err := orderHandler.CheckPriceCollars(); err != nil {
+		log.Fatal(err)
+	}
 
 	// Order endpoints
 	router.HandleFunc("POST /api/orders", orderHandler.PlaceOrder)
 	router.HandleFunc("GET /api/orders", orderHandler.GetAllOrders)
 	router.HandleFunc("GET /api/orders/{orderId}", orderHandler.GetOrderStatus)
+	router.HandleFunc("PATCH /api/orders/{orderId}", orderHandler.AmendOrder)
 	router.HandleFunc("DELETE /api/orders/{orderId}", orderHandler.CancelOrder)
 	router.HandleFunc("GET /api/orderbook", orderHandler.GetOrderBook)
+	router.HandleFunc("POST /api/order-groups", orderHandler.PlaceOrderGroup)
+	router.HandleFunc("GET /api/order-groups/{groupId}", orderHandler.GetOrderGroup)
+	router.HandleFunc("GET /api/symbols/{symbol}", orderHandler.GetSymbolStatus)
+	router.HandleFunc("POST /api/symbols/{symbol}/halt", middleware.AdminOnly(cfg.AdminToken, orderHandler.HaltSymbol))
+	router.HandleFunc("POST /api/symbols/{symbol}/close", middleware.AdminOnly(cfg.AdminToken, orderHandler.CloseSymbol))
+	router.HandleFunc("POST /api/symbols/{symbol}/resume", middleware.AdminOnly(cfg.AdminToken, orderHandler.ResumeSymbol))
+	router.HandleFunc("POST /api/symbols/{symbol}/auction", middleware.AdminOnly(cfg.AdminToken, orderHandler.StartAuction))
+	router.HandleFunc("POST /api/symbols/{symbol}/auction/uncross", middleware.AdminOnly(cfg.AdminToken, orderHandler.UncrossAuction))
+	router.HandleFunc("PUT /api/symbols/{symbol}/mode", middleware.AdminOnly(cfg.AdminToken, orderHandler.SetMatchingMode))
+
+	// Instrument endpoints, changes are admin only
+	router.HandleFunc("GET /api/instruments", orderHandler.ListInstruments)
+	router.HandleFunc("GET /api/instruments/{symbol}", orderHandler.GetInstrument)
+	router.HandleFunc("POST /api/instruments", middleware.AdminOnly(cfg.AdminToken, orderHandler.CreateInstrument))
+	router.HandleFunc("PATCH /api/instruments/{symbol}", middleware.AdminOnly(cfg.AdminToken, orderHandler.UpdateInstrument))
+	router.HandleFunc("DELETE /api/instruments/{symbol}", middleware.AdminOnly(cfg.AdminToken, orderHandler.DeleteInstrument))
 
 	tradeHandler := trade.NewTradeHandler(storage)
 	router.HandleFunc("GET /api/trades", tradeHandler.ListTrades)
@@ -71,6 +92,9 @@ func main() {
 		slog.Error("Failed to shutdown server", slog.String("error", err.Error()))
 	}
 
+	// drain queued order commands before the storage goes away
+	orderHandler.Close()
+
 	// close database connection
 	if err := storage.DB.Close(); err != nil {
 		slog.Error("Failed to close database connection", slog.String("error", err.Error()))
@@ -5,14 +5,28 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/decimal"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
 )
 
 type HTTPServer struct {
 	Addr string `yaml:"address" env-required:"true"`
+	// AdminToken is the bearer token of the admin endpoints, they refuse every request without one
+	AdminToken string `yaml:"admin_token" env:"ADMIN_TOKEN"`
 }
 
+// Lock modes of the database transactions
+const (
+	// LockPessimistic locks rows when they are read with SELECT ... FOR UPDATE
+	LockPessimistic = "pessimistic"
+	// LockOptimistic runs TiDB optimistic transactions, conflicts are detected at commit
+	LockOptimistic = "optimistic"
+)
+
 type Database struct {
 	Host            string `yaml:"host" env-required:"true"`
 	Port            int    `yaml:"port" env-required:"true"`
@@ -20,16 +34,204 @@ type Database struct {
 	Password        string `yaml:"password"`
 	Name            string `yaml:"name" env-required:"true"`
 	ConnMaxLifetime int    `yaml:"conn_max_lifetime" env-default:"3600"`
+	LockMode        string `yaml:"lock_mode" env-default:"pessimistic"`
+}
+
+// What happens to a post only order that would trade on arrival
+const (
+	// PostOnlyReject rejects the order
+	PostOnlyReject = "reject"
+	// PostOnlyReprice moves the order one tick behind the opposite best price
+	PostOnlyReprice = "reprice"
+)
+
+// Matching algorithms sharing an incoming order among the orders resting at a price
+const (
+	// MatchingFIFO fills the oldest order first
+	MatchingFIFO = "fifo"
+	// MatchingProRata shares the quantity in proportion to the resting sizes
+	MatchingProRata = "pro_rata"
+	// MatchingHybrid fills the oldest order first and shares the rest pro-rata
+	MatchingHybrid = "hybrid"
+)
+
+// Reference prices of a price collar
+const (
+	// CollarLastTrade follows the last trade price
+	CollarLastTrade = "last_trade"
+	// CollarPreviousClose is the last trade price before the current trading day
+	CollarPreviousClose = "previous_close"
+	// CollarStatic is the collar's configured price
+	CollarStatic = "static"
+)
+
+// PriceCollar bounds limit prices to Percent around a reference price, the percent has
+// at most two decimals so it counts whole basis points
+type PriceCollar struct {
+	Reference string          `yaml:"reference"`
+	Percent   decimal.Decimal `yaml:"percent"`
+	// Price is the reference price of a static collar, in the symbol's decimals
+	Price decimal.Decimal `yaml:"price"`
+}
+
+// What happens to new orders while a symbol is halted
+const (
+	// HaltedOrdersReject rejects them
+	HaltedOrdersReject = "reject"
+	// HaltedOrdersQueue rests them in the book without trading until the symbol reopens
+	HaltedOrdersQueue = "queue"
+)
+
+// Matching modes of an open symbol
+const (
+	// ModeContinuous matches orders as they arrive
+	ModeContinuous = "continuous"
+	// ModeBatch collects orders for a batch interval and uncrosses them at a single price
+	ModeBatch = "batch"
+)
+
+// How a batch shares the quantity at its clearing price among the orders of the
+// oversubscribed side at that price
+const (
+	// BatchTime fills the oldest order first
+	BatchTime = "time"
+	// BatchProRata shares the quantity in proportion to the resting sizes
+	BatchProRata = "pro_rata"
+)
+
+type Engine struct {
+	// DayEnd is the UTC time of day (HH:MM:SS) at which DAY orders expire
+	DayEnd        string `yaml:"day_end" env-default:"23:59:59"`
+	PostOnly      string `yaml:"post_only" env-default:"reject"`
+	TickSize      int64  `yaml:"tick_size" env-default:"1"`
+	Matching      string `yaml:"matching" env-default:"fifo"`
+	MinAllocation int64  `yaml:"min_allocation" env-default:"1"`
+	// MaxSlippageBps bounds how far from the touch market orders trade, 0 leaves them unbounded
+	MaxSlippageBps int64 `yaml:"max_slippage_bps"`
+
+	// STPMode is the self-trade prevention mode of orders that don't set one
+	STPMode string `yaml:"stp_mode" env-default:"cancel_newest"`
+
+	HaltedOrders string `yaml:"halted_orders" env-default:"reject"`
+	// CircuitBreakerPercent halts a symbol whose price would move more than this within
+	// its last CircuitBreakerTrades trades, 0 turns the breaker off. It has at most two
+	// decimals, whole basis points.
+	CircuitBreakerPercent decimal.Decimal `yaml:"circuit_breaker_percent"`
+	CircuitBreakerTrades  int             `yaml:"circuit_breaker_trades" env-default:"100"`
+	// a symbol halted by its circuit breaker reopens with an auction call after HaltDuration,
+	// the call uncrosses after ReopeningAuction
+	HaltDuration     time.Duration `yaml:"halt_duration" env-default:"5m"`
+	ReopeningAuction time.Duration `yaml:"reopening_auction" env-default:"1m"`
+	// PriceCollars reject limit orders priced outside any of them
+	PriceCollars []PriceCollar `yaml:"price_collars"`
+
+	// Mode is how open symbols match, an admin can switch a symbol at runtime. In batch mode
+	// orders uncross every BatchInterval, BatchAllocation shares the marginal price level.
+	Mode            string        `yaml:"mode" env-default:"continuous"`
+	BatchInterval   time.Duration `yaml:"batch_interval" env-default:"100ms"`
+	BatchAllocation string        `yaml:"batch_allocation" env-default:"time"`
+	// BatchSweepInterval is how often symbols in batch mode are checked for a batch that is
+	// due, it bounds how late a batch uncrosses
+	BatchSweepInterval time.Duration `yaml:"batch_sweep_interval" env-default:"10ms"`
+
+	// Symbols overrides the engine settings per symbol
+	Symbols map[string]Symbol `yaml:"symbols"`
+	// Accounts overrides the engine settings per account
+	Accounts map[string]Account `yaml:"accounts"`
+}
+
+// Account holds the engine settings of one account, empty fields fall back to the engine's
+type Account struct {
+	STPMode string `yaml:"stp_mode"`
+}
+
+// STPModeFor returns the self-trade prevention mode of an account's orders
+func (e Engine) STPModeFor(account string) types.STPMode {
+	if mode := e.Accounts[account].STPMode; mode != "" {
+		return types.STPMode(mode)
+	}
+	return types.STPMode(e.STPMode)
+}
+
+// Symbol holds the engine settings of one symbol, empty fields fall back to the engine's.
+// MaxSlippageBps and CircuitBreakerPercent only fall back when they are left out, a
+// symbol sets them to 0 to turn them off.
+type Symbol struct {
+	Matching              string           `yaml:"matching"`
+	MinAllocation         int64            `yaml:"min_allocation"`
+	MaxSlippageBps        *int64           `yaml:"max_slippage_bps"`
+	CircuitBreakerPercent *decimal.Decimal `yaml:"circuit_breaker_percent"`
+	CircuitBreakerTrades  int              `yaml:"circuit_breaker_trades"`
+	HaltDuration          time.Duration    `yaml:"halt_duration"`
+	ReopeningAuction      time.Duration    `yaml:"reopening_auction"`
+	// PriceCollars replace the engine's collars when set
+	PriceCollars    []PriceCollar `yaml:"price_collars"`
+	Mode            string        `yaml:"mode"`
+	BatchInterval   time.Duration `yaml:"batch_interval"`
+	BatchAllocation string        `yaml:"batch_allocation"`
+}
+
+// Symbol returns the engine settings of a symbol with the defaults filled in
+func (e Engine) Symbol(symbol string) Symbol {
+	s := e.Symbols[symbol]
+	if s.Matching == "" {
+		s.Matching = e.Matching
+	}
+	if s.MinAllocation == 0 {
+		s.MinAllocation = e.MinAllocation
+	}
+	if s.MaxSlippageBps == nil {
+		s.MaxSlippageBps = &e.MaxSlippageBps
+	}
+	if s.CircuitBreakerPercent == nil {
+		s.CircuitBreakerPercent = &e.CircuitBreakerPercent
+	}
+	if s.CircuitBreakerTrades == 0 {
+		s.CircuitBreakerTrades = e.CircuitBreakerTrades
+	}
+	if s.HaltDuration == 0 {
+		s.HaltDuration = e.HaltDuration
+	}
+	if s.ReopeningAuction == 0 {
+		s.ReopeningAuction = e.ReopeningAuction
+	}
+	if len(s.PriceCollars) == 0 {
+		s.PriceCollars = e.PriceCollars
+	}
+	if s.Mode == "" {
+		s.Mode = e.Mode
+	}
+	if s.BatchInterval == 0 {
+		s.BatchInterval = e.BatchInterval
+	}
+	if s.BatchAllocation == "" {
+		s.BatchAllocation = e.BatchAllocation
+	}
+	return s
+}
+
+// DayEndAfter returns the first end of the trading day after t
+func (e Engine) DayEndAfter(t time.Time) time.Time {
+	clock, _ := time.Parse(time.TimeOnly, e.DayEnd)
+
+	t = t.UTC()
+	end := time.Date(t.Year(), t.Month(), t.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, time.UTC)
+	if !end.After(t) {
+		end = end.AddDate(0, 0, 1)
+	}
+
+	return end
 }
 
 type Config struct {
 	Env        string   `yaml:"env" env:"ENV" env-required:"true" env-default:"production"`
 	Database   Database `yaml:"database" env-required:"true"`
+	Engine     Engine   `yaml:"engine"`
 	HTTPServer `yaml:"http_server"`
 }
 
 func (c *Config) DatabaseURL() string {
-	return fmt.Sprintf(
+	url := fmt.Sprintf(
 		"%s:%s@tcp(%s:%d)/%s?parseTime=true",
 		c.Database.User,
 		c.Database.Password,
@@ -37,6 +239,13 @@ func (c *Config) DatabaseURL() string {
 		c.Database.Port,
 		c.Database.Name,
 	)
+
+	// session variable understood by TiDB only, pessimistic is the default there
+	if c.Database.LockMode == LockOptimistic {
+		url += "&tidb_txn_mode=%27optimistic%27"
+	}
+
+	return url
 }
 
 func MustLoad() *Config {
@@ -64,5 +273,111 @@ func MustLoad() *Config {
 		log.Fatalf("Unable to load config: %s", err.Error())
 	}
 
+	switch cfg.Database.LockMode {
+	case LockPessimistic, LockOptimistic:
+	default:
+		log.Fatalf("Invalid database lock mode: %s", cfg.Database.LockMode)
+	}
+
+	if _, err := time.Parse(time.TimeOnly, cfg.Engine.DayEnd); err != nil {
+		log.Fatalf("Invalid engine day end: %s", cfg.Engine.DayEnd)
+	}
+
+	switch cfg.Engine.PostOnly {
+	case PostOnlyReject, PostOnlyReprice:
+	default:
+		log.Fatalf("Invalid engine post only mode: %s", cfg.Engine.PostOnly)
+	}
+
+	if cfg.Engine.TickSize <= 0 {
+		log.Fatalf("Invalid engine tick size: %d", cfg.Engine.TickSize)
+	}
+
+	switch cfg.Engine.HaltedOrders {
+	case HaltedOrdersReject, HaltedOrdersQueue:
+	default:
+		log.Fatalf("Invalid engine halted orders mode: %s", cfg.Engine.HaltedOrders)
+	}
+
+	if cfg.Engine.BatchSweepInterval <= 0 {
+		log.Fatalf("Invalid engine batch sweep interval: %s", cfg.Engine.BatchSweepInterval)
+	}
+
+	// the empty symbol checks the engine defaults
+	symbols := []string{""}
+	for symbol := range cfg.Engine.Symbols {
+		symbols = append(symbols, symbol)
+	}
+	for _, symbol := range symbols {
+		settings := cfg.Engine.Symbol(symbol)
+
+		switch settings.Matching {
+		case MatchingFIFO, MatchingProRata, MatchingHybrid:
+		default:
+			log.Fatalf("Invalid matching algorithm %q for symbol %q", settings.Matching, symbol)
+		}
+
+		if settings.MinAllocation <= 0 {
+			log.Fatalf("Invalid minimum allocation %d for symbol %q", settings.MinAllocation, symbol)
+		}
+
+		if *settings.MaxSlippageBps < 0 || *settings.MaxSlippageBps >= 10000 {
+			log.Fatalf("Invalid max slippage %d bps for symbol %q", *settings.MaxSlippageBps, symbol)
+		}
+
+		if bps, err := settings.CircuitBreakerPercent.Units(2); err != nil || bps < 0 || settings.CircuitBreakerTrades <= 0 {
+			log.Fatalf("Invalid circuit breaker %s%% over %d trades for symbol %q", *settings.CircuitBreakerPercent, settings.CircuitBreakerTrades, symbol)
+		}
+
+		if settings.HaltDuration <= 0 || settings.ReopeningAuction <= 0 {
+			log.Fatalf("Invalid halt duration %s or reopening auction %s for symbol %q", settings.HaltDuration, settings.ReopeningAuction, symbol)
+		}
+
+		switch settings.Mode {
+		case ModeContinuous, ModeBatch:
+		default:
+			log.Fatalf("Invalid matching mode %q for symbol %q", settings.Mode, symbol)
+		}
+
+		switch settings.BatchAllocation {
+		case BatchTime, BatchProRata:
+		default:
+			log.Fatalf("Invalid batch allocation %q for symbol %q", settings.BatchAllocation, symbol)
+		}
+
+		if settings.BatchInterval <= 0 {
+			log.Fatalf("Invalid batch interval %s for symbol %q", settings.BatchInterval, symbol)
+		}
+
+		for _, collar := range settings.PriceCollars {
+			switch collar.Reference {
+			case CollarLastTrade, CollarPreviousClose:
+			case CollarStatic:
+				if collar.Price.Sign() <= 0 {
+					log.Fatalf("Invalid static price collar price %s for symbol %q", collar.Price, symbol)
+				}
+			default:
+				log.Fatalf("Invalid price collar reference %q for symbol %q", collar.Reference, symbol)
+			}
+
+			if bps, err := collar.Percent.Units(2); err != nil || bps <= 0 {
+				log.Fatalf("Invalid price collar of %s%% for symbol %q", collar.Percent, symbol)
+			}
+		}
+	}
+
+	// the empty account checks the engine default
+	accounts := []string{""}
+	for account := range cfg.Engine.Accounts {
+		accounts = append(accounts, account)
+	}
+	for _, account := range accounts {
+		switch cfg.Engine.STPModeFor(account) {
+		case types.CANCEL_NEWEST, types.CANCEL_OLDEST, types.CANCEL_BOTH, types.DECREMENT_CANCEL:
+		default:
+			log.Fatalf("Invalid self-trade prevention mode %q for account %q", cfg.Engine.STPModeFor(account), account)
+		}
+	}
+
 	return &cfg
 }
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/decimal"
+)
+
+func TestSymbol(t *testing.T) {
+	zero := int64(0)
+	off := decimal.New(0, 0)
+	engine := Engine{
+		Matching:              MatchingFIFO,
+		MaxSlippageBps:        100,
+		CircuitBreakerPercent: decimal.New(25, 1),
+		Symbols: map[string]Symbol{
+			"OFF":      {MaxSlippageBps: &zero, CircuitBreakerPercent: &off},
+			"PRO-RATA": {Matching: MatchingProRata},
+		},
+	}
+
+	tests := []struct {
+		symbol         string
+		wantMatching   string
+		wantSlippage   int64
+		wantBreakerBps int64
+	}{
+		{symbol: "OFF", wantMatching: MatchingFIFO, wantSlippage: 0, wantBreakerBps: 0},
+		{symbol: "PRO-RATA", wantMatching: MatchingProRata, wantSlippage: 100, wantBreakerBps: 250},
+		{symbol: "UNLISTED", wantMatching: MatchingFIFO, wantSlippage: 100, wantBreakerBps: 250},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.symbol, func(t *testing.T) {
+			settings := engine.Symbol(tt.symbol)
+			breaker, _ := settings.CircuitBreakerPercent.Units(2)
+			if settings.Matching != tt.wantMatching || *settings.MaxSlippageBps != tt.wantSlippage || breaker != tt.wantBreakerBps {
+				t.Errorf("Symbol(%q) = %s, %d bps, breaker %d bps, want %s, %d bps, breaker %d bps", tt.symbol,
+					settings.Matching, *settings.MaxSlippageBps, breaker, tt.wantMatching, tt.wantSlippage, tt.wantBreakerBps)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+// Package decimal holds the fixed-point numbers prices and quantities are written in.
+// The engine counts them in int64 units of 10^-scale, the scale is the instrument's.
+package decimal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
+	"strings"
+)
+
+// MaxScale is the most decimals a number can have, an int64 holds 18 digits at least
+const MaxScale = 18
+
+var (
+	// ErrSyntax is returned for text that isn't a plain decimal number
+	ErrSyntax = errors.New("invalid decimal number")
+	// ErrPrecision is returned when a number has more decimals than the scale it is converted to
+	ErrPrecision = errors.New("too many decimals")
+	// ErrRange is returned when a number doesn't fit in an int64 of units
+	ErrRange = errors.New("number out of range")
+)
+
+var pow10 [MaxScale + 1]int64
+
+func init() {
+	pow10[0] = 1
+	for i := 1; i <= MaxScale; i++ {
+		pow10[i] = pow10[i-1] * 10
+	}
+}
+
+// Decimal is a number of units of 10^-scale, the zero value is 0
+type Decimal struct {
+	units int64
+	scale int
+}
+
+// New returns the decimal of units at scale
+func New(units int64, scale int) Decimal {
+	return Decimal{units: units, scale: scale}
+}
+
+// NewPtr returns the decimal of *units at scale, nil for nil
+func NewPtr(units *int64, scale int) *Decimal {
+	if units == nil {
+		return nil
+	}
+	d := New(*units, scale)
+	return &d
+}
+
+// Parse reads a decimal number like "-12.50", exponents are not accepted
+func Parse(s string) (Decimal, error) {
+	text := s
+	negative := strings.HasPrefix(text, "-")
+	if negative {
+		text = text[1:]
+	}
+
+	whole, fraction, _ := strings.Cut(text, ".")
+	if whole == "" && fraction == "" {
+		return Decimal{}, fmt.Errorf("%w: %q", ErrSyntax, s)
+	}
+	// trailing zeros don't count against the scale, "1.500" fits in 2 decimals
+	fraction = strings.TrimRight(fraction, "0")
+	if len(fraction) > MaxScale {
+		return Decimal{}, fmt.Errorf("%w: %q", ErrSyntax, s)
+	}
+
+	var units uint64
+	for _, c := range whole + fraction {
+		if c < '0' || c > '9' {
+			return Decimal{}, fmt.Errorf("%w: %q", ErrSyntax, s)
+		}
+		hi, lo := bits.Mul64(units, 10)
+		lo, carry := bits.Add64(lo, uint64(c-'0'), 0)
+		if hi != 0 || carry != 0 || lo > math.MaxInt64 {
+			return Decimal{}, fmt.Errorf("%w: %q", ErrRange, s)
+		}
+		units = lo
+	}
+
+	d := Decimal{units: int64(units), scale: len(fraction)}
+	if negative {
+		d.units = -d.units
+	}
+	return d, nil
+}
+
+// Units returns the number in units of 10^-scale. Trailing zeros are dropped, any other
+// decimal beyond the scale is an ErrPrecision.
+func (d Decimal) Units(scale int) (int64, error) {
+	if scale < 0 || scale > MaxScale {
+		return 0, fmt.Errorf("%w: scale %d", ErrRange, scale)
+	}
+
+	if d.scale > scale {
+		factor := pow10[d.scale-scale]
+		if d.units%factor != 0 {
+			return 0, fmt.Errorf("%w in %s, at most %d", ErrPrecision, d, scale)
+		}
+		return d.units / factor, nil
+	}
+
+	factor := pow10[scale-d.scale]
+	if d.units > math.MaxInt64/factor || d.units < math.MinInt64/factor {
+		return 0, fmt.Errorf("%w: %s", ErrRange, d)
+	}
+	return d.units * factor, nil
+}
+
+// Sign returns -1, 0 or 1 for a negative, zero or positive number
+func (d Decimal) Sign() int {
+	switch {
+	case d.units < 0:
+		return -1
+	case d.units > 0:
+		return 1
+	}
+	return 0
+}
+
+// Cmp compares d and e, it returns -1 when d < e, 0 when they are equal and 1 when d > e
+func (d Decimal) Cmp(e Decimal) int {
+	return d.rat().Cmp(e.rat())
+}
+
+func (d Decimal) rat() *big.Rat {
+	return new(big.Rat).SetFrac(big.NewInt(d.units), big.NewInt(pow10[d.scale]))
+}
+
+// String writes the number with all the decimals of its scale
+func (d Decimal) String() string {
+	if d.scale == 0 {
+		return fmt.Sprintf("%d", d.units)
+	}
+
+	var sign string
+	units := uint64(d.units)
+	if d.units < 0 {
+		sign, units = "-", uint64(-d.units)
+	}
+
+	digits := fmt.Sprintf("%0*d", d.scale+1, units)
+	point := len(digits) - d.scale
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// MarshalJSON writes the number as a JSON string, so no decimal is lost to a float
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON reads a number written as a JSON string or a JSON number
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	text := string(data)
+	if strings.HasPrefix(text, `"`) {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+	}
+
+	parsed, err := Parse(text)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// UnmarshalText reads a number from configuration files
+func (d *Decimal) UnmarshalText(text []byte) error {
+	parsed, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Pow10 returns 10^scale, the units of one whole number at scale
+func Pow10(scale int) int64 {
+	return pow10[scale]
+}
+
+// MulDiv returns a * b / c rounded towards zero, the product doesn't overflow on the way.
+// It reports false when c is zero or the result doesn't fit in an int64 again.
+func MulDiv(a, b, c int64) (int64, bool) {
+	if c == 0 {
+		return 0, false
+	}
+
+	negative := (a < 0) != (b < 0) != (c < 0)
+	hi, lo := bits.Mul64(abs(a), abs(b))
+	if hi >= abs(c) {
+		return 0, false
+	}
+	quotient, _ := bits.Div64(hi, lo, abs(c))
+
+	if negative {
+		if quotient > 1<<63 {
+			return 0, false
+		}
+		return -int64(quotient), true
+	}
+	if quotient > math.MaxInt64 {
+		return 0, false
+	}
+	return int64(quotient), true
+}
+
+func abs(x int64) uint64 {
+	if x < 0 {
+		return uint64(-x)
+	}
+	return uint64(x)
+}
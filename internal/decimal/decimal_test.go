@@ -0,0 +1,214 @@
+package decimal
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text      string
+		wantUnits int64
+		wantScale int
+		wantErr   error
+	}{
+		{text: "12", wantUnits: 12},
+		{text: "12.50", wantUnits: 125, wantScale: 1},
+		{text: "-0.05", wantUnits: -5, wantScale: 2},
+		{text: ".5", wantUnits: 5, wantScale: 1},
+		{text: "3.", wantUnits: 3},
+		{text: "9223372036854775807", wantUnits: math.MaxInt64},
+		{text: "0.000000000000000001", wantUnits: 1, wantScale: 18},
+		{text: "1.0000000000000000000", wantUnits: 1},
+		{text: "0.0000000000000000001", wantErr: ErrSyntax},
+		{text: "9223372036854775808", wantErr: ErrRange},
+		{text: "92233720368547758.08", wantErr: ErrRange},
+		{text: "", wantErr: ErrSyntax},
+		{text: "-", wantErr: ErrSyntax},
+		{text: ".", wantErr: ErrSyntax},
+		{text: "+1", wantErr: ErrSyntax},
+		{text: "1e3", wantErr: ErrSyntax},
+		{text: "1.2.3", wantErr: ErrSyntax},
+		{text: " 1", wantErr: ErrSyntax},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := Parse(tt.text)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Parse(%q) error = %v, want %v", tt.text, err, tt.wantErr)
+			}
+			if want := New(tt.wantUnits, tt.wantScale); err == nil && got != want {
+				t.Errorf("Parse(%q) = %#v, want %#v", tt.text, got, want)
+			}
+		})
+	}
+}
+
+func TestUnits(t *testing.T) {
+	tests := []struct {
+		name    string
+		d       Decimal
+		scale   int
+		want    int64
+		wantErr error
+	}{
+		{name: "scales up", d: New(125, 1), scale: 4, want: 125000},
+		{name: "drops trailing zeros", d: New(1500, 3), scale: 1, want: 15},
+		{name: "keeps the sign", d: New(-125, 2), scale: 3, want: -1250},
+		{name: "zero fits any scale", d: Decimal{}, scale: MaxScale, want: 0},
+		{name: "too many decimals for the scale", d: New(125, 2), scale: 1, wantErr: ErrPrecision},
+		{name: "negative with too many decimals", d: New(-1, 3), scale: 2, wantErr: ErrPrecision},
+		{name: "overflows int64", d: New(math.MaxInt64, 0), scale: 1, wantErr: ErrRange},
+		{name: "overflows int64 when negative", d: New(math.MinInt64/10-1, 0), scale: 1, wantErr: ErrRange},
+		{name: "largest scale that fits", d: New(9, 0), scale: MaxScale, want: 9 * Pow10(MaxScale)},
+		{name: "negative scale", d: New(1, 0), scale: -1, wantErr: ErrRange},
+		{name: "scale beyond the maximum", d: New(1, 0), scale: MaxScale + 1, wantErr: ErrRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.d.Units(tt.scale)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("%s.Units(%d) error = %v, want %v", tt.d, tt.scale, err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("%s.Units(%d) = %d, want %d", tt.d, tt.scale, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		d    Decimal
+		want string
+	}{
+		{d: Decimal{}, want: "0"},
+		{d: New(42, 0), want: "42"},
+		{d: New(-42, 0), want: "-42"},
+		{d: New(1250, 2), want: "12.50"},
+		{d: New(100, 2), want: "1.00"},
+		{d: New(5, 3), want: "0.005"},
+		{d: New(-5, 3), want: "-0.005"},
+		{d: New(0, 2), want: "0.00"},
+		{d: New(math.MinInt64, 0), want: "-9223372036854775808"},
+		{d: New(math.MinInt64, 18), want: "-9.223372036854775808"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.d.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// parsed numbers lose their trailing zeros, the scale is only as long as it needs to be
+	if got, _ := Parse("1.500"); got.String() != "1.5" {
+		t.Errorf("Parse(%q).String() = %q, want %q", "1.500", got.String(), "1.5")
+	}
+}
+
+func TestCmp(t *testing.T) {
+	tests := []struct {
+		d, e Decimal
+		want int
+	}{
+		{d: New(15, 1), e: New(150, 2), want: 0},
+		{d: New(15, 1), e: New(149, 2), want: 1},
+		{d: New(-15, 1), e: New(-149, 2), want: -1},
+		{d: Decimal{}, e: New(0, 5), want: 0},
+		{d: New(math.MaxInt64, 0), e: New(math.MaxInt64, 18), want: 1},
+	}
+
+	for _, tt := range tests {
+		if got := tt.d.Cmp(tt.e); got != tt.want {
+			t.Errorf("%s.Cmp(%s) = %d, want %d", tt.d, tt.e, got, tt.want)
+		}
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    Decimal
+		wantErr bool
+	}{
+		{data: `"12.50"`, want: New(125, 1)},
+		{data: `12.50`, want: New(125, 1)},
+		{data: `-3`, want: New(-3, 0)},
+		{data: `"-0.001"`, want: New(-1, 3)},
+		{data: `null`, want: New(7, 0)},
+		{data: `1e2`, wantErr: true},
+		{data: `""`, wantErr: true},
+		{data: `"abc"`, wantErr: true},
+		{data: `"9223372036854775808"`, wantErr: true},
+		{data: `true`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			// null leaves the number as it was
+			got := New(7, 0)
+			err := json.Unmarshal([]byte(tt.data), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal(%s) error = %v, want error %v", tt.data, err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("Unmarshal(%s) = %s, want %s", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	for _, d := range []Decimal{New(125, 2), New(-5, 3), New(42, 0), New(math.MaxInt64, 4)} {
+		data, err := json.Marshal(d)
+		if err != nil {
+			t.Fatalf("Marshal(%s) error = %v", d, err)
+		}
+		if want := `"` + d.String() + `"`; string(data) != want {
+			t.Errorf("Marshal(%s) = %s, want %s", d, data, want)
+		}
+
+		var got Decimal
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("Unmarshal(%s) error = %v", data, err)
+		}
+		if got.Cmp(d) != 0 {
+			t.Errorf("round trip of %s = %s", d, got)
+		}
+	}
+}
+
+func TestMulDiv(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b, c int64
+		want    int64
+		wantOK  bool
+	}{
+		{name: "exact", a: 10000, b: 250, c: 10000, want: 250, wantOK: true},
+		{name: "rounds towards zero", a: 7, b: 3, c: 2, want: 10, wantOK: true},
+		{name: "rounds negatives towards zero", a: -7, b: 3, c: 2, want: -10, wantOK: true},
+		{name: "negative divisor", a: 7, b: 3, c: -2, want: -10, wantOK: true},
+		{name: "product beyond int64", a: math.MaxInt64, b: 1000, c: 1000, want: math.MaxInt64, wantOK: true},
+		{name: "smallest int64", a: math.MinInt64, b: 1, c: 1, want: math.MinInt64, wantOK: true},
+		{name: "result beyond int64", a: math.MaxInt64, b: 2, c: 1, wantOK: false},
+		{name: "negative result beyond int64", a: math.MinInt64, b: 2, c: 1, wantOK: false},
+		{name: "smallest int64 negated", a: math.MinInt64, b: -1, c: 1, wantOK: false},
+		{name: "division by zero", a: 1, b: 1, c: 0, wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := MulDiv(tt.a, tt.b, tt.c)
+			if ok != tt.wantOK || ok && got != tt.want {
+				t.Errorf("MulDiv(%d, %d, %d) = %d, %v, want %d, %v", tt.a, tt.b, tt.c, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
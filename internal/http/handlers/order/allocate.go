@@ -0,0 +1,61 @@
+package order
+
+import (
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/matching"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+)
+
+// allocate shares quantity among the orders of a level with the algorithm. Displayed
+// orders have priority, hidden orders share what they leave over.
+func allocate(algorithm matching.Algorithm, orders []*types.Order, quantity int64) []matching.Allocation {
+	var displayed, hidden []*types.Order
+	for _, order := range orders {
+		if order.Hidden {
+			hidden = append(hidden, order)
+		} else {
+			displayed = append(displayed, order)
+		}
+	}
+
+	allocations := allocateEligible(algorithm, displayed, quantity)
+	for _, allocation := range allocations {
+		// an iceberg trading its whole slice shows the next one ahead of the hidden orders,
+		// the level is shared again once it has
+		order := allocation.Order
+		if order.IsIceberg() && allocation.Quantity == order.Tradable() && order.Remaining > allocation.Quantity {
+			return allocations
+		}
+		quantity -= allocation.Quantity
+	}
+	if quantity > 0 && len(hidden) > 0 {
+		allocations = append(allocations, allocateEligible(algorithm, hidden, quantity)...)
+	}
+	return allocations
+}
+
+// allocateEligible shares quantity among orders with the algorithm. Orders whose share
+// would be below their minimum quantity are left out and the orders are shared again
+// without them, they keep their place in the queue.
+func allocateEligible(algorithm matching.Algorithm, orders []*types.Order, quantity int64) []matching.Allocation {
+	for {
+		allocations := algorithm.Allocate(orders, quantity)
+
+		short := make(map[int64]bool)
+		for _, allocation := range allocations {
+			if allocation.Quantity < allocation.Order.MinFill() {
+				short[allocation.Order.OrderID] = true
+			}
+		}
+		if len(short) == 0 {
+			return allocations
+		}
+
+		eligible := make([]*types.Order, 0, len(orders)-len(short))
+		for _, order := range orders {
+			if !short[order.OrderID] {
+				eligible = append(eligible, order)
+			}
+		}
+		orders = eligible
+	}
+}
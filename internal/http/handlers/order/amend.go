@@ -0,0 +1,202 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/config"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/orderbook"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/storage"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+)
+
+var (
+	errOrderNotAmendable   = errors.New("only open, partially filled or triggered orders can be amended")
+	errPriceNotAmendable   = errors.New("price can only be amended on limit and stop_limit orders")
+	errPegNotAmendable     = errors.New("the price of pegged orders follows their peg")
+	errDarkNotAmendable    = errors.New("dark orders can't be amended, cancel and place a new one")
+	errQuantityBelowFilled = errors.New("quantity must be above the filled quantity")
+	errNothingToAmend      = errors.New("amendment doesn't change the order")
+	errPostOnlyWouldTrade  = errors.New("amended post only order would trade")
+)
+
+// amendOrder changes the price and/or total quantity of a working order. Lowering the
+// quantity keeps the order's time priority, a new price or a larger quantity sends it
+// to the back of its level and matches it again.
+func (h *OrderHandler) amendOrder(book *orderbook.OrderBook, orderID int64, newPrice, newQuantity *int64) (amended types.Order, trades []types.Trade, err error) {
+	tx, err := h.Storage.Begin()
+	if err != nil {
+		return amended, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// the book is changed before the transaction commits, rebuild it on failure
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			h.loadBook(book)
+			panic(r)
+		} else if err != nil {
+			tx.Rollback()
+			if err := h.loadBook(book); err != nil {
+				slog.Error("Failed to reload order book", "error", err)
+			}
+		}
+	}()
+
+	stored, err := h.Storage.GetOrderStatusTx(tx, orderID)
+	if errors.Is(err, storage.ErrOrderNotFound) {
+		return amended, nil, errOrderNotFound
+	}
+	if err != nil {
+		return amended, nil, fmt.Errorf("failed to fetch order: %w", err)
+	}
+
+	switch stored.Status {
+	case types.OPEN, types.PARTIAL, types.TRIGGERED:
+	default:
+		return amended, nil, errOrderNotAmendable
+	}
+
+	if _, ok := book.GetDark(orderID); ok {
+		return amended, nil, errDarkNotAmendable
+	}
+
+	order, pending := book.GetStop(orderID)
+	if !pending {
+		var ok bool
+		if order, ok = book.Get(orderID); !ok {
+			return amended, nil, errOrderNotAmendable
+		}
+	}
+
+	// a book copy that disagrees with the locked row missed a concurrent fill or cancel,
+	// the deferred rollback reloads the book before the retry
+	if stored.Status != order.Status || stored.Quantity != order.Quantity || stored.Remaining != order.Remaining || stored.VisibleRemaining != order.VisibleRemaining {
+		slog.Warn("Order book is stale, reloading from storage",
+			"symbol", book.Symbol,
+			"order_id", orderID)
+		return amended, nil, storage.ErrWriteConflict
+	}
+
+	quantity := stored.Quantity
+	if newQuantity != nil {
+		quantity = *newQuantity
+	}
+	if quantity <= stored.Quantity-stored.Remaining {
+		return amended, nil, errQuantityBelowFilled
+	}
+
+	price := order.Price
+	if newPrice != nil {
+		if order.Price == nil {
+			return amended, nil, errPriceNotAmendable
+		}
+		if order.IsPegged() {
+			return amended, nil, errPegNotAmendable
+		}
+		price = newPrice
+	}
+
+	priceChanged := price != nil && *price != *order.Price
+	if !priceChanged && quantity == order.Quantity {
+		return amended, nil, errNothingToAmend
+	}
+
+	// priority is kept only when the quantity goes down at the same price
+	requeue := priceChanged || quantity > order.Quantity
+
+	if requeue && (book.Phase == types.CLOSED || book.Phase == types.HALTED && h.Engine.HaltedOrders == config.HaltedOrdersReject) {
+		return amended, nil, errSymbolHalted
+	}
+
+	if order.PostOnly && priceChanged {
+		probe := *order
+		probe.Price = price
+		h.checkPostOnly(book, &probe)
+		if probe.Status == types.REJECTED || *probe.Price != *price {
+			return amended, nil, errPostOnlyWouldTrade
+		}
+	}
+
+	if priceChanged {
+		probe := *order
+		probe.Price = price
+		if err = h.checkPriceCollars(book, &probe, time.Now()); err != nil {
+			return amended, nil, err
+		}
+		if probe.Status == types.REJECTED {
+			return amended, nil, errPriceOutsideCollar
+		}
+	}
+
+	switch {
+	case pending:
+		// pending stops keep their place in the trigger book, only the order changes
+		h.resize(order, quantity)
+		order.Price = price
+
+		if err = h.Storage.AmendOrder(tx, *order, false); err != nil {
+			return amended, nil, fmt.Errorf("failed to amend order %d: %w", orderID, err)
+		}
+
+	case !requeue:
+		book.Reduce(order, order.Quantity-quantity)
+		order.Quantity = quantity
+		h.updateOrderStatus(order)
+
+		if err = h.Storage.AmendOrder(tx, *order, false); err != nil {
+			return amended, nil, fmt.Errorf("failed to amend order %d: %w", orderID, err)
+		}
+
+	default:
+		book.Remove(orderID)
+		h.resize(order, quantity)
+		order.Price = price
+		h.updateOrderStatus(order)
+
+		if err = h.Storage.AmendOrder(tx, *order, true); err != nil {
+			return amended, nil, fmt.Errorf("failed to amend order %d: %w", orderID, err)
+		}
+
+		// the new price may cross the book, whatever is left rests at the back of its level
+		trades, _, err = h.processOrder(tx, book, order)
+		if err != nil {
+			return amended, nil, fmt.Errorf("failed to process amended order: %w", err)
+		}
+
+		var triggeredTrades []types.Trade
+		triggeredTrades, _, err = h.triggerStops(tx, book)
+		if err != nil {
+			return amended, nil, fmt.Errorf("failed to trigger stop orders: %w", err)
+		}
+		for _, trade := range triggeredTrades {
+			if trade.BuyOrderID == orderID || trade.SellOrderID == orderID {
+				trades = append(trades, trade)
+			}
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return amended, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	slog.Info("Order amended",
+		"order_id", orderID,
+		"quantity", order.Quantity,
+		"remaining", order.Remaining,
+		"requeue", requeue)
+
+	return *order, trades, nil
+}
+
+// resize sets a new total quantity on an order outside the book, the filled part stays filled
+func (h *OrderHandler) resize(order *types.Order, quantity int64) {
+	order.Remaining += quantity - order.Quantity
+	order.Quantity = quantity
+
+	if order.IsIceberg() {
+		order.VisibleRemaining = min(order.DisplayQuantity, order.Remaining)
+	}
+}
@@ -0,0 +1,278 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/config"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/orderbook"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/storage"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/utils/response"
+)
+
+var (
+	errAuctionRunning = errors.New("symbol is already in an auction call")
+	errNoAuction      = errors.New("symbol is not in an auction call")
+)
+
+// StartAuction starts the auction call of a symbol, its orders accumulate without trading.
+// A halted or closed symbol reopens through the call.
+func (h *OrderHandler) StartAuction(w http.ResponseWriter, r *http.Request) {
+	symbol := r.PathValue("symbol")
+
+	err := h.submit(symbol, func(book *orderbook.OrderBook) error {
+		if book.Phase == types.AUCTION {
+			return errAuctionRunning
+		}
+		return retryOnConflict(func() error {
+			return h.setPhase(book, types.SymbolStatus{Symbol: symbol, Phase: types.AUCTION})
+		})
+	})
+	switch {
+	case errors.Is(err, storage.ErrInstrumentNotFound):
+		response.WriteJson(w, http.StatusNotFound, response.GeneralError(storage.ErrInstrumentNotFound))
+		return
+	case errors.Is(err, errAuctionRunning):
+		response.WriteJson(w, http.StatusConflict, response.GeneralError(err))
+		return
+	case err != nil:
+		slog.Error("Failed to start auction", "symbol", symbol, "error", err)
+		response.WriteJson(w, http.StatusInternalServerError, response.GeneralErrorString("failed to start auction"))
+		return
+	}
+
+	response.WriteJson(w, http.StatusOK, map[string]any{
+		"message": "auction started successfully",
+		"data": map[string]any{
+			"symbol": symbol,
+			"phase":  types.AUCTION,
+		},
+	})
+}
+
+// UncrossAuction ends the auction call of a symbol, the crossed part of the book trades
+// at the uncrossing price and the symbol goes back to its matching mode
+func (h *OrderHandler) UncrossAuction(w http.ResponseWriter, r *http.Request) {
+	symbol := r.PathValue("symbol")
+
+	var (
+		auction types.Auction
+		trades  []types.Trade
+		phase   types.TradingPhase
+	)
+	err := h.submit(symbol, func(book *orderbook.OrderBook) error {
+		if book.Phase != types.AUCTION {
+			return errNoAuction
+		}
+		return retryOnConflict(func() error {
+			var err error
+			auction, trades, err = h.uncross(book)
+			phase = book.Phase
+			return err
+		})
+	})
+	switch {
+	case errors.Is(err, storage.ErrInstrumentNotFound):
+		response.WriteJson(w, http.StatusNotFound, response.GeneralError(storage.ErrInstrumentNotFound))
+		return
+	case errors.Is(err, errNoAuction):
+		response.WriteJson(w, http.StatusConflict, response.GeneralError(err))
+		return
+	case err != nil:
+		slog.Error("Failed to uncross auction", "symbol", symbol, "error", err)
+		response.WriteJson(w, http.StatusInternalServerError, response.GeneralErrorString("failed to uncross auction"))
+		return
+	}
+
+	response.WriteJson(w, http.StatusOK, map[string]any{
+		"message": "auction uncrossed successfully",
+		"data": map[string]any{
+			"symbol":  symbol,
+			"phase":   phase,
+			"auction": auction,
+			"trades":  trades,
+		},
+	})
+}
+
+// checkPhase rejects the orders the symbol's trading phase can't take. Closed symbols
+// take none, halted ones none or, when they queue, the same orders as an auction call:
+// no orders that only live for an immediate match and no post only orders, which would
+// take liquidity in the uncross, no orders with a minimum quantity, which sit it out, and
+// no pegged orders, the book they would follow is crossed. Batch matching takes the same
+// orders minus stop orders trading at market once triggered and dark orders, the symbol
+// never trades continuously for them.
+func (h *OrderHandler) checkPhase(book *orderbook.OrderBook, order *types.Order) {
+	if book.Phase == types.CONTINUOUS || order.Status == types.REJECTED {
+		return
+	}
+
+	call := "the auction call"
+	if book.Phase == types.BATCH {
+		call = "batch matching"
+	}
+
+	switch {
+	case book.Phase == types.CLOSED:
+		order.Reason = "symbol is closed"
+	case book.Phase == types.HALTED && h.Engine.HaltedOrders == config.HaltedOrdersReject:
+		order.Reason = "symbol is halted"
+	case order.OrderType == types.MARKET:
+		order.Reason = "market orders are not accepted during " + call
+	case !order.IsStop() && (order.TimeInForce == types.IOC || order.TimeInForce == types.FOK):
+		order.Reason = "ioc and fok orders are not accepted during " + call
+	case order.PostOnly:
+		order.Reason = "post only orders are not accepted during " + call
+	case order.IsPegged():
+		order.Reason = "pegged orders are not accepted during " + call
+	case order.MinFill() > 0:
+		order.Reason = "min quantity and all or none orders are not accepted during " + call
+	case book.Phase == types.BATCH && order.IsStop() && order.IsMarket():
+		order.Reason = "stop and trailing stop orders are not accepted during batch matching, stop limit orders are"
+	case book.Phase == types.BATCH && order.Dark:
+		order.Reason = "dark orders are not accepted during batch matching"
+	default:
+		return
+	}
+	order.Status = types.REJECTED
+}
+
+// uncross trades the crossed part of the book at the single uncrossing price, best bids
+// against best asks in priority order, then hands the symbol back to its matching mode.
+// Stops crossed by the auction price trigger once the symbol is open again, the circuit
+// breaker starts over from the auction price.
+func (h *OrderHandler) uncross(book *orderbook.OrderBook) (auction types.Auction, trades []types.Trade, err error) {
+	tx, err := h.Storage.Begin()
+	if err != nil {
+		return auction, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// the book is changed before the transaction commits, rebuild it on failure
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			h.loadBook(book)
+			panic(r)
+		} else if err != nil {
+			tx.Rollback()
+			if err := h.loadBook(book); err != nil {
+				slog.Error("Failed to reload order book", "error", err)
+			}
+		}
+	}()
+
+	book.ClearPrices()
+
+	auction = book.Uncross()
+	if auction.IndicativePrice != nil {
+		trades, err = h.uncrossAt(tx, book, *auction.IndicativePrice, auction.IndicativeVolume, nil)
+		if err != nil {
+			return auction, nil, err
+		}
+	}
+
+	if err = h.Storage.SetSymbolStatus(tx, types.SymbolStatus{Symbol: book.Symbol, Phase: book.Mode}); err != nil {
+		return auction, nil, fmt.Errorf("failed to set trading phase: %w", err)
+	}
+	book.Phase, book.PhaseUntil = book.Mode, h.batchEnd(book, time.Now())
+
+	triggeredTrades, _, err := h.triggerStops(tx, book)
+	if err != nil {
+		return auction, nil, fmt.Errorf("failed to trigger stop orders: %w", err)
+	}
+	trades = append(trades, triggeredTrades...)
+
+	if err = tx.Commit(); err != nil {
+		return auction, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	var price int64
+	if auction.IndicativePrice != nil {
+		price = *auction.IndicativePrice
+	}
+	slog.Info("Auction uncrossed",
+		"symbol", book.Symbol,
+		"price", price,
+		"volume", auction.IndicativeVolume,
+		"imbalance", auction.Imbalance,
+		"imbalance_side", auction.ImbalanceSide)
+
+	return auction, trades, nil
+}
+
+// uncrossAt trades up to volume between the bids at or above price and the asks at or
+// below it. The newer order of each pair stands in for the incoming one, its self-trade
+// prevention mode applies. Orders with a quota trade no more than it, the others in
+// priority order.
+func (h *OrderHandler) uncrossAt(tx storage.Tx, book *orderbook.OrderBook, price, volume int64, quotas map[int64]int64) ([]types.Trade, error) {
+	var trades []types.Trade
+
+	now := time.Now()
+	for volume > 0 {
+		bid, ask := auctionBest(book, types.BUY, quotas), auctionBest(book, types.SELL, quotas)
+		if bid == nil || ask == nil || *bid.Price < price || *ask.Price > price {
+			break
+		}
+
+		// the sweeper may not have caught up with expired orders yet
+		if bid.Expired(now) || ask.Expired(now) {
+			for _, order := range []*types.Order{bid, ask} {
+				if order.Expired(now) {
+					if err := h.expireOrder(tx, book, order); err != nil {
+						return nil, err
+					}
+				}
+			}
+			continue
+		}
+
+		fresh := true
+		for _, order := range []*types.Order{bid, ask} {
+			ok, err := h.lockRestingOrder(tx, book, order)
+			if err != nil {
+				return nil, err
+			}
+			fresh = fresh && ok
+		}
+		if !fresh {
+			continue
+		}
+
+		newer, older := bid, ask
+		if ask.OrderID > bid.OrderID {
+			newer, older = ask, bid
+		}
+
+		if newer.IsSelfTrade(older) {
+			// both orders rest, a decremented one keeps its place in its level
+			if _, err := h.preventSelfTrade(tx, book, newer, older); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		quantity := min(bid.Tradable(), ask.Tradable(), volume)
+		for _, order := range []*types.Order{bid, ask} {
+			if quota, ok := quotas[order.OrderID]; ok {
+				quantity = min(quantity, quota)
+			}
+		}
+		trade, err := h.executeTrade(tx, book, newer, older, quantity, price)
+		if err != nil {
+			return nil, err
+		}
+		for _, order := range []*types.Order{bid, ask} {
+			if _, ok := quotas[order.OrderID]; ok {
+				quotas[order.OrderID] -= quantity
+			}
+		}
+		trades = append(trades, trade)
+		volume -= quantity
+	}
+
+	return trades, nil
+}
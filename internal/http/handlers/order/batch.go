@@ -0,0 +1,309 @@
+package order
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/config"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/decimal"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/orderbook"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/storage"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/utils/response"
+	"github.com/go-playground/validator/v10"
+)
+
+var errSameMode = errors.New("symbol already runs in this matching mode")
+
+// SetMatchingMode switches a symbol between continuous and batch matching. Leaving batch
+// matching uncrosses the orders collected so far, a halted, closed or auction symbol
+// opens in the new mode.
+func (h *OrderHandler) SetMatchingMode(w http.ResponseWriter, r *http.Request) {
+	symbol := r.PathValue("symbol")
+
+	var modeBody types.SetMatchingModeRequest
+	if err := json.NewDecoder(r.Body).Decode(&modeBody); err != nil {
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralErrorString("invalid request body"))
+		return
+	}
+
+	if err := validator.New().Struct(modeBody); err != nil {
+		validateErrors := err.(validator.ValidationErrors)
+		response.WriteJson(w, http.StatusBadRequest, response.ValidationError(validateErrors))
+		return
+	}
+
+	var (
+		trades []types.Trade
+		phase  types.TradingPhase
+	)
+	err := h.submit(symbol, func(book *orderbook.OrderBook) error {
+		if book.Mode == modeBody.Mode {
+			return errSameMode
+		}
+		return retryOnConflict(func() error {
+			var err error
+			trades, err = h.switchMode(book, modeBody.Mode)
+			phase = book.Phase
+			return err
+		})
+	})
+	switch {
+	case errors.Is(err, storage.ErrInstrumentNotFound):
+		response.WriteJson(w, http.StatusNotFound, response.GeneralError(storage.ErrInstrumentNotFound))
+		return
+	case errors.Is(err, errSameMode):
+		response.WriteJson(w, http.StatusConflict, response.GeneralError(err))
+		return
+	case err != nil:
+		slog.Error("Failed to change matching mode", "symbol", symbol, "mode", modeBody.Mode, "error", err)
+		response.WriteJson(w, http.StatusInternalServerError, response.GeneralErrorString("failed to change matching mode"))
+		return
+	}
+
+	response.WriteJson(w, http.StatusOK, map[string]any{
+		"message": "matching mode changed successfully",
+		"data": map[string]any{
+			"symbol": symbol,
+			"mode":   modeBody.Mode,
+			"phase":  phase,
+			"trades": trades,
+		},
+	})
+}
+
+// switchMode stores the new matching mode of a symbol and moves an open symbol into it
+func (h *OrderHandler) switchMode(book *orderbook.OrderBook, mode types.TradingPhase) (trades []types.Trade, err error) {
+	tx, err := h.Storage.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// the book is changed before the transaction commits, rebuild it on failure
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			h.loadBook(book)
+			panic(r)
+		} else if err != nil {
+			tx.Rollback()
+			if err := h.loadBook(book); err != nil {
+				slog.Error("Failed to reload order book", "error", err)
+			}
+		}
+	}()
+
+	if err = h.Storage.SetMatchingMode(tx, book.Symbol, mode); err != nil {
+		return nil, fmt.Errorf("failed to set matching mode: %w", err)
+	}
+	book.Mode = mode
+
+	if book.Phase == types.CONTINUOUS || book.Phase == types.BATCH {
+		if book.Phase == types.BATCH {
+			// the orders collected so far uncross as a last batch
+			if trades, err = h.uncrossBatch(tx, book); err != nil {
+				return nil, err
+			}
+		}
+
+		if err = h.Storage.SetSymbolStatus(tx, types.SymbolStatus{Symbol: book.Symbol, Phase: mode}); err != nil {
+			return nil, fmt.Errorf("failed to set trading phase: %w", err)
+		}
+		book.Phase, book.PhaseUntil = mode, h.batchEnd(book, time.Now())
+
+		triggeredTrades, _, err := h.triggerStops(tx, book)
+		if err != nil {
+			return nil, fmt.Errorf("failed to trigger stop orders: %w", err)
+		}
+		trades = append(trades, triggeredTrades...)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	slog.Info("Matching mode changed", "symbol", book.Symbol, "mode", mode, "phase", book.Phase)
+
+	return trades, nil
+}
+
+// matchingMode returns the mode a symbol trades in while open, the one set by an admin
+// or else the configured one
+func (h *OrderHandler) matchingMode(symbol string, status *types.SymbolStatus) types.TradingPhase {
+	if status.Mode != "" {
+		return status.Mode
+	}
+	return types.TradingPhase(h.Engine.Symbol(symbol).Mode)
+}
+
+// batchEnd returns when a batch starting at now uncrosses, nil outside batch matching
+func (h *OrderHandler) batchEnd(book *orderbook.OrderBook, now time.Time) *time.Time {
+	if book.Phase != types.BATCH {
+		return nil
+	}
+	end := now.Add(h.Engine.Symbol(book.Symbol).BatchInterval)
+	return &end
+}
+
+// trackBatching records whether a book is in batch matching after a command ran on it
+func (h *OrderHandler) trackBatching(book *orderbook.OrderBook) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if book.Phase == types.BATCH {
+		h.batching[book.Symbol] = true
+	} else {
+		delete(h.batching, book.Symbol)
+	}
+}
+
+// runBatches uncrosses the due batch of every symbol in batch matching
+func (h *OrderHandler) runBatches(now time.Time) {
+	h.mu.Lock()
+	symbols := make([]string, 0, len(h.batching))
+	for symbol := range h.batching {
+		symbols = append(symbols, symbol)
+	}
+	h.mu.Unlock()
+
+	for _, symbol := range symbols {
+		err := h.submit(symbol, func(book *orderbook.OrderBook) error {
+			if book.Phase != types.BATCH || book.PhaseUntil != nil && now.Before(*book.PhaseUntil) {
+				return nil
+			}
+			return retryOnConflict(func() error {
+				return h.runBatch(book, now)
+			})
+		})
+		if err != nil {
+			slog.Error("Failed to uncross batch", "symbol", symbol, "error", err)
+		}
+	}
+}
+
+// runBatch ends the running batch of a symbol, the crossed part of the book trades at a
+// single clearing price, and starts the next one. Stops crossed by the clearing price
+// trigger right after, a triggered stop limit order waits for the next batch.
+func (h *OrderHandler) runBatch(book *orderbook.OrderBook, now time.Time) (err error) {
+	// nothing trades in a batch that isn't crossed, the next one starts right away
+	if !book.Crossed() {
+		book.PhaseUntil = h.batchEnd(book, now)
+		return nil
+	}
+
+	tx, err := h.Storage.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// the book is changed before the transaction commits, rebuild it on failure
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			h.loadBook(book)
+			panic(r)
+		} else if err != nil {
+			tx.Rollback()
+			if err := h.loadBook(book); err != nil {
+				slog.Error("Failed to reload order book", "error", err)
+			}
+		}
+	}()
+
+	if _, err = h.uncrossBatch(tx, book); err != nil {
+		return err
+	}
+
+	if _, _, err = h.triggerStops(tx, book); err != nil {
+		return fmt.Errorf("failed to trigger stop orders: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	book.PhaseUntil = h.batchEnd(book, now)
+
+	return nil
+}
+
+// uncrossBatch trades the crossed part of the book at the price an auction would uncross
+// it and returns the trades. The symbol's batch allocation shares the clearing price level
+// of the oversubscribed side.
+func (h *OrderHandler) uncrossBatch(tx storage.Tx, book *orderbook.OrderBook) ([]types.Trade, error) {
+	auction := book.Uncross()
+	if auction.IndicativePrice == nil {
+		return nil, nil
+	}
+
+	trades, err := h.uncrossAt(tx, book, *auction.IndicativePrice, auction.IndicativeVolume, h.batchQuotas(book, auction))
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("Batch uncrossed",
+		"symbol", book.Symbol,
+		"price", *auction.IndicativePrice,
+		"volume", auction.IndicativeVolume,
+		"imbalance", auction.Imbalance,
+		"imbalance_side", auction.ImbalanceSide)
+
+	return trades, nil
+}
+
+// batchQuotas shares what the oversubscribed side trades at its marginal level, the one
+// trading only in part, pro-rata among the orders resting there. Better priced levels
+// trade in full. It is nil when the symbol allocates in time priority or neither side is
+// oversubscribed.
+func (h *OrderHandler) batchQuotas(book *orderbook.OrderBook, auction types.Auction) map[int64]int64 {
+	settings := h.Engine.Symbol(book.Symbol)
+	if settings.BatchAllocation != config.BatchProRata || auction.Imbalance == 0 {
+		return nil
+	}
+
+	left := auction.IndicativeVolume
+	for _, level := range book.Levels(auction.ImbalanceSide) {
+		var (
+			orders []*types.Order
+			total  int64
+		)
+		for _, order := range level.Orders() {
+			// orders with a minimum quantity sit the batch out
+			if order.MinFill() == 0 {
+				orders = append(orders, order)
+				total += order.Remaining
+			}
+		}
+		if total <= left {
+			left -= total
+			continue
+		}
+
+		// shares are rounded down to whole lots and shares below the minimum allocation
+		// are dropped, what is left over after rounding goes to the orders in time priority
+		lot := max(book.LotSize, 1)
+		quotas := make(map[int64]int64, len(orders))
+		shared := left
+		for _, order := range orders {
+			share, ok := decimal.MulDiv(shared, order.Remaining, total)
+			share -= share % lot
+			if !ok || share < settings.MinAllocation*lot {
+				share = 0
+			}
+			quotas[order.OrderID] = share
+			left -= share
+		}
+		for _, order := range orders {
+			fill := min(left, order.Remaining-quotas[order.OrderID])
+			quotas[order.OrderID] += fill
+			left -= fill
+		}
+		return quotas
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package order
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/config"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+)
+
+func TestBatchQuotas(t *testing.T) {
+	minQuantity := bid(101, 6)
+	minQuantity.MinQuantity = 6
+
+	tests := []struct {
+		name          string
+		allocation    string
+		minAllocation int64
+		lotSize       int64
+		orders        []*types.Order
+		want          map[int64]int64
+	}{
+		{
+			name:          "time allocation has no quotas",
+			allocation:    config.BatchTime,
+			minAllocation: 1,
+			orders:        []*types.Order{ask(100, 5), bid(101, 6), bid(101, 4)},
+		},
+		{
+			name:          "a balanced batch has no quotas",
+			allocation:    config.BatchProRata,
+			minAllocation: 1,
+			orders:        []*types.Order{ask(100, 10), bid(101, 6), bid(101, 4)},
+		},
+		{
+			name:          "the marginal level shares pro-rata, the remainder goes in time priority",
+			allocation:    config.BatchProRata,
+			minAllocation: 1,
+			orders:        []*types.Order{ask(100, 5), bid(101, 6), bid(101, 3), bid(101, 1)},
+			want:          map[int64]int64{2: 4, 3: 1, 4: 0},
+		},
+		{
+			name:          "better priced levels trade in full",
+			allocation:    config.BatchProRata,
+			minAllocation: 1,
+			orders:        []*types.Order{bid(102, 2), bid(101, 6), bid(101, 4), ask(100, 6)},
+			want:          map[int64]int64{2: 3, 3: 1},
+		},
+		{
+			name:          "the oversubscribed sell side is shared",
+			allocation:    config.BatchProRata,
+			minAllocation: 1,
+			orders:        []*types.Order{ask(100, 3), ask(100, 1), bid(101, 2)},
+			want:          map[int64]int64{1: 2, 2: 0},
+		},
+		{
+			name:          "shares are whole lots and shares below the minimum allocation are dropped",
+			allocation:    config.BatchProRata,
+			minAllocation: 2,
+			lotSize:       10,
+			orders:        []*types.Order{ask(100, 50), bid(101, 60), bid(101, 30), bid(101, 10)},
+			want:          map[int64]int64{2: 50, 3: 0, 4: 0},
+		},
+		{
+			name:          "minimum quantity orders sit the batch out",
+			allocation:    config.BatchProRata,
+			minAllocation: 1,
+			orders:        []*types.Order{minQuantity, bid(101, 4), bid(101, 4), ask(100, 4)},
+			want:          map[int64]int64{2: 2, 3: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &OrderHandler{Engine: config.Engine{BatchAllocation: tt.allocation, MinAllocation: tt.minAllocation}}
+
+			book := bookOf(tt.orders...)
+			book.LotSize = max(tt.lotSize, 1)
+
+			got := h.batchQuotas(book, book.Uncross())
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("batchQuotas() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package order
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/orderbook"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/storage"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+)
+
+// validateBracket checks the take-profit and stop-loss legs of an order. The take profit
+// has to sit on the profitable side of the parent's limit price, where it can't trade
+// against the parent, and of the stop loss.
+func validateBracket(req *types.PlaceOrderRequest) error {
+	if req.TakeProfit == nil {
+		return nil
+	}
+
+	if (req.Type == types.LIMIT || req.Type == types.STOP_LIMIT) && req.Price != nil {
+		profit := req.TakeProfit.Price.Cmp(*req.Price)
+		if req.Side == types.BUY && profit <= 0 {
+			return fmt.Errorf("take_profit price must be above the price of a buy order")
+		}
+		if req.Side == types.SELL && profit >= 0 {
+			return fmt.Errorf("take_profit price must be below the price of a sell order")
+		}
+	}
+
+	if req.StopLoss == nil {
+		return nil
+	}
+
+	profit := req.TakeProfit.Price.Cmp(*req.StopLoss.StopPrice)
+	if req.Side == types.BUY && profit <= 0 {
+		return fmt.Errorf("take_profit price must be above the stop_loss stop_price of a buy order")
+	}
+	if req.Side == types.SELL && profit >= 0 {
+		return fmt.Errorf("take_profit price must be below the stop_loss stop_price of a sell order")
+	}
+
+	return nil
+}
+
+// newBracket creates the bracket group of an order with take-profit or stop-loss legs,
+// nil when the order has none
+func newBracket(req *types.PlaceOrderRequest, instrument *types.Instrument) (*types.OrderGroup, error) {
+	if req.TakeProfit == nil && req.StopLoss == nil {
+		return nil, nil
+	}
+
+	bracket := &types.OrderGroup{
+		Type:       types.BRACKET,
+		OnFill:     types.FILL_CANCELS,
+		PriceScale: instrument.PriceScale,
+	}
+
+	var err error
+	if req.TakeProfit != nil {
+		if bracket.TakeProfitPrice, err = priceUnits(instrument, "take_profit price", req.TakeProfit.Price); err != nil {
+			return nil, err
+		}
+	}
+	if req.StopLoss != nil {
+		if bracket.StopLossPrice, err = priceUnits(instrument, "stop_loss stop_price", req.StopLoss.StopPrice); err != nil {
+			return nil, err
+		}
+		if bracket.StopLossLimitPrice, err = priceUnits(instrument, "stop_loss price", req.StopLoss.Price); err != nil {
+			return nil, err
+		}
+	}
+
+	return bracket, nil
+}
+
+// fillBracketParent sizes the legs of a bracket to what its parent has filled. The
+// first fill opens them, later fills grow the legs that are still working by the
+// filled quantity.
+func (h *OrderHandler) fillBracketParent(tx storage.Tx, book *orderbook.OrderBook, group *types.OrderGroup, parent *types.Order, quantity int64) error {
+	var takeProfit, stopLoss *types.Order
+	for _, stored := range group.Orders[1:] {
+		if stored.IsStop() {
+			stopLoss = stored
+		} else {
+			takeProfit = stored
+		}
+	}
+
+	if group.TakeProfitPrice != nil {
+		var err error
+		if takeProfit == nil {
+			err = h.openBracketLeg(tx, book, group, newTakeProfit(group, parent, quantity))
+		} else {
+			err = h.growBracketLeg(tx, book, group, takeProfit, quantity)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if group.StopLossPrice != nil {
+		var err error
+		if stopLoss == nil {
+			err = h.openBracketLeg(tx, book, group, h.newStopLoss(group, parent, quantity))
+		} else {
+			err = h.growBracketLeg(tx, book, group, stopLoss, quantity)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// newTakeProfit creates the limit order closing a bracket's position at the take-profit price
+func newTakeProfit(group *types.OrderGroup, parent *types.Order, quantity int64) *types.Order {
+	return &types.Order{
+		Symbol:        parent.Symbol,
+		AccountID:     parent.AccountID,
+		Side:          parent.Side.Opposite(),
+		OrderType:     types.LIMIT,
+		Price:         group.TakeProfitPrice,
+		Quantity:      quantity,
+		Remaining:     quantity,
+		PriceScale:    parent.PriceScale,
+		QuantityScale: parent.QuantityScale,
+		STPMode:       parent.STPMode,
+		Status:        types.OPEN,
+		TimeInForce:   types.GTC,
+		GroupID:       &group.GroupID,
+	}
+}
+
+// newStopLoss creates the stop order closing a bracket's position at the stop-loss price,
+// a stop loss with a limit price is a stop limit order
+func (h *OrderHandler) newStopLoss(group *types.OrderGroup, parent *types.Order, quantity int64) *types.Order {
+	order := &types.Order{
+		Symbol:        parent.Symbol,
+		AccountID:     parent.AccountID,
+		Side:          parent.Side.Opposite(),
+		OrderType:     types.STOP,
+		StopPrice:     group.StopLossPrice,
+		Quantity:      quantity,
+		Remaining:     quantity,
+		PriceScale:    parent.PriceScale,
+		QuantityScale: parent.QuantityScale,
+		STPMode:       parent.STPMode,
+		Status:        types.OPEN,
+		TimeInForce:   types.IOC,
+		GroupID:       &group.GroupID,
+	}
+
+	if group.StopLossLimitPrice != nil {
+		order.OrderType = types.STOP_LIMIT
+		order.Price = group.StopLossLimitPrice
+		order.TimeInForce = types.GTC
+	} else {
+		order.MaxSlippageBps = *h.Engine.Symbol(parent.Symbol).MaxSlippageBps
+	}
+
+	return order
+}
+
+// openBracketLeg stores a new leg of a bracket. A stop loss waits in the trigger book, a
+// take profit could trade so it starts working once the current match is done.
+func (h *OrderHandler) openBracketLeg(tx storage.Tx, book *orderbook.OrderBook, group *types.OrderGroup, leg *types.Order) error {
+	orderID, err := h.Storage.PlaceOrder(tx, *leg)
+	if err != nil {
+		return fmt.Errorf("failed to place bracket order: %w", err)
+	}
+	leg.OrderID = orderID
+
+	if leg.IsStop() {
+		book.AddStop(leg)
+	} else {
+		book.Activate(leg.OrderID)
+	}
+
+	slog.Info("Bracket order opened", "group_id", group.GroupID, "order_id", leg.OrderID, "type", leg.OrderType, "quantity", leg.Quantity)
+
+	return nil
+}
+
+// growBracketLeg adds quantity to a working leg of a bracket. A leg that stopped working,
+// filled or cancelled by a fill of the other leg, isn't reopened, what the parent fills
+// afterwards only grows the legs still working.
+func (h *OrderHandler) growBracketLeg(tx storage.Tx, book *orderbook.OrderBook, group *types.OrderGroup, stored *types.Order, quantity int64) error {
+	switch stored.Status {
+	case types.OPEN, types.PARTIAL, types.TRIGGERED:
+	default:
+		return nil
+	}
+
+	if resting, ok := book.Get(stored.OrderID); ok {
+		// a larger order goes to the back of its level like an amended one
+		book.Remove(resting.OrderID)
+		h.resize(resting, resting.Quantity+quantity)
+		h.updateOrderStatus(resting)
+		if err := h.Storage.AmendOrder(tx, *resting, true); err != nil {
+			return fmt.Errorf("failed to grow bracket order %d: %w", resting.OrderID, err)
+		}
+		book.Add(resting)
+	} else {
+		// a pending stop loss, or a take profit that hasn't started working yet
+		leg := stored
+		if pending, ok := book.GetStop(stored.OrderID); ok {
+			leg = pending
+		}
+		h.resize(leg, leg.Quantity+quantity)
+		if err := h.Storage.AmendOrder(tx, *leg, false); err != nil {
+			return fmt.Errorf("failed to grow bracket order %d: %w", leg.OrderID, err)
+		}
+	}
+
+	slog.Info("Bracket order grown", "group_id", group.GroupID, "order_id", stored.OrderID, "quantity", quantity)
+
+	return nil
+}
+
+// workActivated matches the orders activated during the last match, they are read
+// back from storage as they may have grown since
+func (h *OrderHandler) workActivated(tx storage.Tx, book *orderbook.OrderBook) ([]types.Trade, []types.SelfTrade, bool, error) {
+	var (
+		trades     []types.Trade
+		selfTrades []types.SelfTrade
+	)
+
+	activated := book.TakeActivated()
+	for _, orderID := range activated {
+		order, err := h.Storage.GetOrderStatusTx(tx, orderID)
+		if err != nil {
+			return nil, nil, false, fmt.Errorf("failed to lock order %d: %w", orderID, err)
+		}
+		if order.Status != types.OPEN {
+			continue
+		}
+
+		cancelled, err := h.cancelOutsideCollars(tx, book, order)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		if cancelled {
+			continue
+		}
+
+		orderTrades, orderSelfTrades, err := h.processOrder(tx, book, order)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		trades = append(trades, orderTrades...)
+		selfTrades = append(selfTrades, orderSelfTrades...)
+	}
+
+	return trades, selfTrades, len(activated) > 0, nil
+}
@@ -0,0 +1,80 @@
+package order
+
+import (
+	"testing"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/decimal"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/orderbook"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+)
+
+func TestValidateBracket(t *testing.T) {
+	price := func(units int64) *decimal.Decimal {
+		d := decimal.New(units, 0)
+		return &d
+	}
+
+	tests := []struct {
+		name       string
+		req        types.PlaceOrderRequest
+		wantReject bool
+	}{
+		{
+			name: "a buy takes profit above its price and stop loss",
+			req:  types.PlaceOrderRequest{Side: types.BUY, Type: types.LIMIT, Price: price(50), TakeProfit: &types.TakeProfitRequest{Price: price(60)}, StopLoss: &types.StopLossRequest{StopPrice: price(45)}},
+		},
+		{
+			name:       "a buy's take profit at its price would trade against it",
+			req:        types.PlaceOrderRequest{Side: types.BUY, Type: types.LIMIT, Price: price(50), TakeProfit: &types.TakeProfitRequest{Price: price(50)}},
+			wantReject: true,
+		},
+		{
+			name:       "a sell's take profit above its price would trade against it",
+			req:        types.PlaceOrderRequest{Side: types.SELL, Type: types.STOP_LIMIT, Price: price(50), TakeProfit: &types.TakeProfitRequest{Price: price(55)}},
+			wantReject: true,
+		},
+		{
+			name: "a sell takes profit below its price",
+			req:  types.PlaceOrderRequest{Side: types.SELL, Type: types.LIMIT, Price: price(50), TakeProfit: &types.TakeProfitRequest{Price: price(40)}},
+		},
+		{
+			name: "a market order has no price to compare with",
+			req:  types.PlaceOrderRequest{Side: types.BUY, Type: types.MARKET, TakeProfit: &types.TakeProfitRequest{Price: price(1)}},
+		},
+		{
+			name:       "a buy's take profit below its stop loss",
+			req:        types.PlaceOrderRequest{Side: types.BUY, Type: types.MARKET, TakeProfit: &types.TakeProfitRequest{Price: price(45)}, StopLoss: &types.StopLossRequest{StopPrice: price(45)}},
+			wantReject: true,
+		},
+		{
+			name: "a stop loss alone",
+			req:  types.PlaceOrderRequest{Side: types.BUY, Type: types.LIMIT, Price: price(50), StopLoss: &types.StopLossRequest{StopPrice: price(55)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateBracket(&tt.req)
+			if (err != nil) != tt.wantReject {
+				t.Errorf("validateBracket() error = %v, want rejection %v", err, tt.wantReject)
+			}
+		})
+	}
+}
+
+func TestGrowBracketLegStopped(t *testing.T) {
+	h := &OrderHandler{}
+	book := orderbook.New("TEST")
+	group := &types.OrderGroup{GroupID: 1, Type: types.BRACKET}
+
+	// a leg that stopped working stays as it is, nothing is stored
+	for _, status := range []types.OrderStatus{types.FILLED, types.CANCELLED, types.EXPIRED} {
+		leg := &types.Order{OrderID: 2, Status: status, Quantity: 5, Remaining: 0}
+		if err := h.growBracketLeg(nil, book, group, leg, 3); err != nil {
+			t.Fatalf("growBracketLeg() of a %s leg error = %v", status, err)
+		}
+		if leg.Quantity != 5 || leg.Remaining != 0 {
+			t.Errorf("growBracketLeg() grew a %s leg to %d, %d remaining", status, leg.Quantity, leg.Remaining)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/config"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/decimal"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/orderbook"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/storage"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+)
+
+var errPriceOutsideCollar = errors.New("price is outside the symbol's price collars")
+
+// checkPriceCollars rejects an order whose limit price is outside any of the symbol's
+// collars, limit, stop limit and pegged orders alike. A stop is checked once it triggers,
+// its limit price doesn't reach the book before. A collar without a reference price yet,
+// like the last trade of a symbol that never traded, lets every price through.
+func (h *OrderHandler) checkPriceCollars(book *orderbook.OrderBook, order *types.Order, now time.Time) error {
+	if order.IsMarket() || order.Price == nil || order.Status == types.REJECTED {
+		return nil
+	}
+	if order.IsStop() && order.Status != types.TRIGGERED {
+		return nil
+	}
+
+	for _, collar := range h.Engine.Symbol(book.Symbol).PriceCollars {
+		reference, err := h.collarReference(book, collar, now)
+		if err != nil {
+			return err
+		}
+		if reference == nil {
+			continue
+		}
+
+		bps, err := collar.Percent.Units(2)
+		if err != nil {
+			return fmt.Errorf("invalid price collar of %s: %w", book.Symbol, err)
+		}
+		// a band wider than an int64 holds lets every price through
+		band, ok := decimal.MulDiv(*reference, bps, 10000)
+		if !ok {
+			continue
+		}
+
+		distance := *order.Price - *reference
+		if distance < -band || distance > band {
+			order.Status = types.REJECTED
+			order.Reason = fmt.Sprintf("price %s is outside the %s%% collar around the %s price %s",
+				decimal.New(*order.Price, book.PriceScale), collar.Percent, collar.Reference, decimal.New(*reference, book.PriceScale))
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// collarReference returns the reference price of a collar, nil when there is none
+func (h *OrderHandler) collarReference(book *orderbook.OrderBook, collar config.PriceCollar, now time.Time) (*int64, error) {
+	switch collar.Reference {
+	case config.CollarStatic:
+		price, err := collar.Price.Units(book.PriceScale)
+		if err != nil {
+			return nil, fmt.Errorf("invalid static price collar of %s: %w", book.Symbol, err)
+		}
+		return &price, nil
+	case config.CollarPreviousClose:
+		// the close only changes once a day, it is looked up once per trading day
+		day := h.Engine.DayEndAfter(now).AddDate(0, 0, -1)
+		if price, ok := book.PreviousClose(day); ok {
+			return price, nil
+		}
+
+		price, err := h.Storage.GetLastTradePriceBefore(book.Symbol, day)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get previous close of %s: %w", book.Symbol, err)
+		}
+		book.SetPreviousClose(day, price)
+		return price, nil
+	default:
+		if price, ok := book.LastPrice(); ok {
+			return &price, nil
+		}
+		return nil, nil
+	}
+}
+
+// cancelOutsideCollars cancels an order reaching the book after it was placed, a triggered
+// stop or an activated bracket leg, when its limit price is outside the symbol's collars.
+// It reports whether the order was cancelled.
+func (h *OrderHandler) cancelOutsideCollars(tx storage.Tx, book *orderbook.OrderBook, order *types.Order) (bool, error) {
+	if err := h.checkPriceCollars(book, order, time.Now()); err != nil {
+		return false, err
+	}
+	if order.Status != types.REJECTED {
+		return false, nil
+	}
+
+	order.Status = types.CANCELLED
+	if err := h.Storage.MarkOrderCancelled(tx, order.OrderID, order.Reason); err != nil {
+		return false, fmt.Errorf("failed to cancel order %d: %w", order.OrderID, err)
+	}
+	slog.Info("Order cancelled outside the price collars", "order_id", order.OrderID, "reason", order.Reason)
+
+	return true, nil
+}
+
+// validateCollars checks that the static collar prices of an instrument's symbol fit its
+// price scale, a price with more decimals couldn't be compared with any order
+func (h *OrderHandler) validateCollars(instrument types.Instrument) error {
+	for _, collar := range h.Engine.Symbol(instrument.Symbol).PriceCollars {
+		if collar.Reference != config.CollarStatic {
+			continue
+		}
+		if _, err := collar.Price.Units(instrument.PriceScale); err != nil {
+			return fmt.Errorf("%w: static price collar %s of %s doesn't fit its price scale %d", errInvalidInstrument, collar.Price, instrument.Symbol, instrument.PriceScale)
+		}
+	}
+	return nil
+}
+
+// CheckPriceCollars validates the static collar prices against the listed instruments,
+// the configuration loads before the instruments and their price scales are known
+func (h *OrderHandler) CheckPriceCollars() error {
+	instruments, err := h.Storage.ListInstruments()
+	if err != nil {
+		return fmt.Errorf("failed to list instruments: %w", err)
+	}
+
+	for _, instrument := range instruments {
+		if err := h.validateCollars(instrument); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/orderbook"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/storage"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+)
+
+// matchDark crosses the dark book at the midpoint of the lit book and returns the trades
+// and prevented self trades. The oldest bid willing to pay the midpoint trades with the
+// oldest ask willing to sell there, until no such pair is left. A pair of the same account
+// doesn't trade, the newer order's self-trade prevention mode applies as it would in the
+// lit book. Dark orders only match in continuous trading while both sides of the lit book
+// show a price, expired ones don't match at all.
+func (h *OrderHandler) matchDark(tx storage.Tx, book *orderbook.OrderBook) ([]types.Trade, []types.SelfTrade, error) {
+	var (
+		trades     []types.Trade
+		selfTrades []types.SelfTrade
+	)
+
+	if book.Phase != types.CONTINUOUS {
+		return nil, nil, nil
+	}
+
+	mid, ok := darkMidpoint(book)
+	if !ok {
+		return nil, nil, nil
+	}
+
+	// dark orders past their expiry that the sweeper hasn't reached expire instead of trading
+	now := time.Now()
+	for _, side := range []types.OrderSide{types.BUY, types.SELL} {
+		for _, order := range book.Dark(side) {
+			if !order.Expired(now) {
+				continue
+			}
+			if err := h.expireOrder(tx, book, order); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	for {
+		bid, ask := darkPair(book, mid)
+		if bid == nil {
+			return trades, selfTrades, nil
+		}
+
+		for _, order := range []*types.Order{bid, ask} {
+			if err := h.lockDarkOrder(tx, order); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		if bid.IsSelfTrade(ask) {
+			newer, older := bid, ask
+			if ask.OrderID > bid.OrderID {
+				newer, older = ask, bid
+			}
+
+			selfTrade, err := h.preventSelfTrade(tx, book, newer, older)
+			if err != nil {
+				return nil, nil, err
+			}
+			selfTrades = append(selfTrades, selfTrade)
+
+			for _, order := range []*types.Order{bid, ask} {
+				if order.Status == types.CANCELLED {
+					book.RemoveDark(order.OrderID)
+				}
+			}
+			continue
+		}
+
+		trade, err := h.executeDarkTrade(tx, book, bid, ask, min(bid.Remaining, ask.Remaining), mid)
+		if err != nil {
+			return nil, nil, err
+		}
+		trades = append(trades, trade)
+	}
+}
+
+// darkMidpoint returns the midpoint of the best displayed bid and offer on the tick grid.
+// A midpoint between two ticks is rounded down to the lower one, it never falls below the
+// bid.
+func darkMidpoint(book *orderbook.OrderBook) (int64, bool) {
+	bid, hasBid := displayedBest(book, types.BUY)
+	ask, hasAsk := displayedBest(book, types.SELL)
+	if !hasBid || !hasAsk {
+		return 0, false
+	}
+
+	// a bid off the grid, left from a larger tick size, keeps the midpoint in the spread
+	mid := (bid + ask) / (2 * book.TickSize) * book.TickSize
+	return max(mid, bid), true
+}
+
+// displayedBest returns the best price of a side that shows any quantity
+func displayedBest(book *orderbook.OrderBook, side types.OrderSide) (int64, bool) {
+	for _, level := range book.Levels(side) {
+		if level.Quantity > 0 {
+			return level.Price, true
+		}
+	}
+	return 0, false
+}
+
+// darkPair returns the oldest bid and ask of the dark book whose limits both allow a trade
+// at the midpoint, both are nil when there is none
+func darkPair(book *orderbook.OrderBook, mid int64) (*types.Order, *types.Order) {
+	asks := book.Dark(types.SELL)
+	for _, bid := range book.Dark(types.BUY) {
+		if *bid.Price < mid {
+			continue
+		}
+		for _, ask := range asks {
+			if *ask.Price <= mid {
+				return bid, ask
+			}
+		}
+	}
+	return nil, nil
+}
+
+// lockDarkOrder locks a dark order's row and makes sure the dark book still holds what
+// storage does. A stale order fails the command so it reruns on a reloaded book.
+func (h *OrderHandler) lockDarkOrder(tx storage.Tx, order *types.Order) error {
+	stored, err := h.Storage.GetOrderStatusTx(tx, order.OrderID)
+	if err != nil && !errors.Is(err, storage.ErrOrderNotFound) {
+		return fmt.Errorf("failed to lock dark order %d: %w", order.OrderID, err)
+	}
+	if err == nil && stored.Status == order.Status && stored.Remaining == order.Remaining {
+		return nil
+	}
+
+	slog.Warn("Dark book is stale, reloading from storage", "symbol", order.Symbol, "order_id", order.OrderID)
+	return storage.ErrWriteConflict
+}
+
+// executeDarkTrade trades quantity between a dark bid and a dark ask at the midpoint and
+// persists both orders. A dark trade doesn't set the last trade price.
+func (h *OrderHandler) executeDarkTrade(tx storage.Tx, book *orderbook.OrderBook, bid, ask *types.Order, tradeQuantity int64, tradePrice int64) (types.Trade, error) {
+	trade := types.Trade{
+		Symbol:        bid.Symbol,
+		BuyOrderID:    bid.OrderID,
+		SellOrderID:   ask.OrderID,
+		Price:         tradePrice,
+		Quantity:      tradeQuantity,
+		PriceScale:    book.PriceScale,
+		QuantityScale: book.QuantityScale,
+		Venue:         types.DARK,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+
+	tradeID, err := h.Storage.CreateTrade(tx, trade)
+	if err != nil {
+		slog.Error("Failed to create trade", "error", err)
+		return trade, fmt.Errorf("failed to create trade: %w", err)
+	}
+	trade.TradeID = tradeID
+
+	for _, order := range []*types.Order{bid, ask} {
+		order.Remaining -= tradeQuantity
+		h.updateOrderStatus(order)
+		if order.Remaining == 0 {
+			book.RemoveDark(order.OrderID)
+		}
+
+		if err := h.Storage.UpdateOrder(tx, *order); err != nil {
+			slog.Error("Failed to update dark order", "error", err)
+			return trade, fmt.Errorf("failed to update order %d: %w", order.OrderID, err)
+		}
+	}
+
+	slog.Info("Dark trade executed",
+		"trade_id", trade.TradeID,
+		"symbol", trade.Symbol,
+		"price", tradePrice,
+		"quantity", tradeQuantity)
+
+	return trade, nil
+}
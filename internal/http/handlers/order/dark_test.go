@@ -0,0 +1,80 @@
+package order
+
+import (
+	"testing"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+)
+
+func TestDarkMidpoint(t *testing.T) {
+	hidden := ask(51, 5)
+	hidden.Hidden = true
+
+	tests := []struct {
+		name     string
+		tickSize int64
+		orders   []*types.Order
+		// want 0 expects no midpoint
+		want int64
+	}{
+		{
+			name:     "an even spread has an exact midpoint",
+			tickSize: 1,
+			orders:   []*types.Order{bid(50, 5), ask(54, 5)},
+			want:     52,
+		},
+		{
+			name:     "an odd spread rounds down to the lower tick",
+			tickSize: 1,
+			orders:   []*types.Order{bid(50, 5), ask(53, 5)},
+			want:     51,
+		},
+		{
+			name:     "a midpoint between ticks rounds down to the lower tick",
+			tickSize: 5,
+			orders:   []*types.Order{bid(50, 5), ask(65, 5)},
+			want:     55,
+		},
+		{
+			name:     "a one tick spread trades at the bid",
+			tickSize: 5,
+			orders:   []*types.Order{bid(50, 5), ask(55, 5)},
+			want:     50,
+		},
+		{
+			name:     "a bid off the grid keeps the midpoint in the spread",
+			tickSize: 5,
+			orders:   []*types.Order{bid(53, 5), ask(55, 5)},
+			want:     53,
+		},
+		{
+			name:     "hidden orders don't set the midpoint",
+			tickSize: 1,
+			orders:   []*types.Order{bid(50, 5), hidden, ask(56, 5)},
+			want:     53,
+		},
+		{
+			name:     "no midpoint without an offer",
+			tickSize: 1,
+			orders:   []*types.Order{bid(50, 5)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			book := bookOf(tt.orders...)
+			book.TickSize = tt.tickSize
+
+			got, ok := darkMidpoint(book)
+			if tt.want == 0 {
+				if ok {
+					t.Errorf("darkMidpoint() = %d, want none", got)
+				}
+				return
+			}
+			if !ok || got != tt.want {
+				t.Errorf("darkMidpoint() = %d, %v, want %d", got, ok, tt.want)
+			}
+		})
+	}
+}
@@ -1,157 +1,678 @@
 package order
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"time"
 
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/config"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/decimal"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/matching"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/orderbook"
 	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/storage"
 	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
 )
 
-// processOrder and returns the executed trades
-func (h *OrderHandler) processOrder(tx storage.Tx, newOrder *types.Order) ([]types.Trade, error) {
-	var trades []types.Trade
+const (
+	// sequencerQueueSize is how many commands may wait for a symbol before submitters block
+	sequencerQueueSize = 256
+	// maxConflictRetries bounds how often a command is rerun after a transaction conflict
+	maxConflictRetries = 3
+)
 
-	// determine opposite side for matching
-	oppositeSide := types.SELL
-	if newOrder.Side == types.SELL {
-		oppositeSide = types.BUY
+var (
+	errOrderNotFound      = errors.New("order not found")
+	errOrderFilled        = errors.New("cannot cancel a filled order")
+	errOrderCancelled     = errors.New("order is already cancelled")
+	errOrderExpired       = errors.New("order is already expired")
+	errOrderRejected      = errors.New("order was rejected")
+	errInvalidOrderStatus = errors.New("invalid order status")
+)
+
+// getBook returns the in-memory order book of a symbol, loading it from storage on first use
+func (h *OrderHandler) getBook(symbol string) (*orderbook.OrderBook, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if book, ok := h.books[symbol]; ok {
+		return book, nil
+	}
+
+	book := orderbook.New(symbol)
+	book.SetPriceWindow(h.Engine.Symbol(symbol).CircuitBreakerTrades)
+	if err := h.loadBook(book); err != nil {
+		return nil, err
 	}
+	h.books[symbol] = book
 
-	// get matching orders from opposite side
-	matchingOrders, err := h.Storage.GetMatchingOrders(newOrder.Symbol, &oppositeSide)
+	return book, nil
+}
+
+// loadBook rebuilds a book from the open orders persisted in storage, only listed
+// instruments have a book
+func (h *OrderHandler) loadBook(book *orderbook.OrderBook) error {
+	instrument, err := h.Storage.GetInstrument(book.Symbol)
 	if err != nil {
-		slog.Error("Failed to get matching orders", "error", err)
-		return nil, fmt.Errorf("failed to get matching orders: %w", err)
+		return fmt.Errorf("failed to load instrument %s: %w", book.Symbol, err)
 	}
 
-	// process matching
-	for _, matchingOrder := range matchingOrders {
-		// check if orders can match based on price
-		if !h.canMatch(newOrder, matchingOrder) {
-			// for limit orders, skip to next order if not matched
-			// for market orders, will match with any opposite side order
-			if newOrder.OrderType == types.LIMIT {
-				continue
+	orders, err := h.Storage.GetMatchingOrders(book.Symbol, nil)
+	if err != nil {
+		return fmt.Errorf("failed to load order book for %s: %w", book.Symbol, err)
+	}
+
+	lastPrice, err := h.Storage.GetLastTradePrice(book.Symbol)
+	if err != nil {
+		return fmt.Errorf("failed to load last trade price for %s: %w", book.Symbol, err)
+	}
+
+	status, err := h.Storage.GetSymbolStatus(book.Symbol)
+	if err != nil {
+		return fmt.Errorf("failed to load trading phase for %s: %w", book.Symbol, err)
+	}
+
+	book.Reset(orders)
+	book.Phase, book.PhaseUntil = status.Phase, status.Until
+	book.Mode = h.matchingMode(book.Symbol, status)
+	// an open symbol trades in its matching mode, a reloaded batch starts over
+	if book.Phase == types.CONTINUOUS || book.Phase == types.BATCH {
+		book.Phase, book.PhaseUntil = book.Mode, h.batchEnd(book, time.Now())
+	}
+	book.TickSize, book.LotSize = instrument.TickSize, instrument.LotSize
+	book.MinQuantity, book.MaxQuantity = instrument.MinQuantity, instrument.MaxQuantity
+	book.PriceScale, book.QuantityScale = instrument.PriceScale, instrument.QuantityScale
+	if lastPrice != nil {
+		book.SetLastPrice(*lastPrice)
+	}
+
+	return nil
+}
+
+// submit runs fn on the sequencer of a symbol, so every command touching the
+// symbol's book and orders is applied one at a time
+func (h *OrderHandler) submit(symbol string, fn func(book *orderbook.OrderBook) error) error {
+	// every symbol gets a queue of its own for good, so unknown symbols don't get one
+	h.mu.Lock()
+	_, loaded := h.books[symbol]
+	h.mu.Unlock()
+	if !loaded {
+		if _, err := h.Storage.GetInstrument(symbol); err != nil {
+			return fmt.Errorf("failed to load instrument %s: %w", symbol, err)
+		}
+	}
+
+	return h.sequencer.Submit(symbol, func() error {
+		book, err := h.getBook(symbol)
+		if err != nil {
+			return err
+		}
+		// the batch sweeper only visits the symbols in batch matching
+		defer h.trackBatching(book)
+		return fn(book)
+	})
+}
+
+// placeOrder persists a new order and matches it within a single transaction, it returns
+// the order's trades and the self trades that were prevented. With a bracket the order
+// is stored as the parent of the group, its fills open the bracket's legs.
+func (h *OrderHandler) placeOrder(book *orderbook.OrderBook, order *types.Order, bracket *types.OrderGroup) (trades []types.Trade, selfTrades []types.SelfTrade, err error) {
+	tx, err := h.Storage.Begin()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// defer rollback in case of error, the book may already hold changes of the
+	// rolled back transaction so it is rebuilt from storage
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			h.loadBook(book)
+			panic(r) // re-throw panic after rollback
+		} else if err != nil {
+			tx.Rollback()
+			if err := h.loadBook(book); err != nil {
+				slog.Error("Failed to reload order book", "error", err)
 			}
 		}
+	}()
 
-		// calculate trade, minimum of remaining quantities
-		tradeQuantity := min(newOrder.Remaining, matchingOrder.Remaining)
-		if tradeQuantity <= 0 {
-			continue
+	// orders the symbol's trading phase can't take are rejected before they are stored
+	h.checkPhase(book, order)
+
+	// pegged orders start at the current price of their peg
+	h.startPeg(book, order)
+
+	// post only orders are rejected or repriced before they are stored
+	h.checkPostOnly(book, order)
+
+	// limit prices have to stay within the symbol's price collars
+	if err = h.checkPriceCollars(book, order, time.Now()); err != nil {
+		return nil, nil, err
+	}
+
+	// trailing stops start trailing from the current price
+	h.startTrailing(book, order)
+
+	// an order spending a quote amount is sized from the asks
+	h.sizeQuoteOrder(book, order)
+
+	if bracket != nil && order.Status != types.REJECTED {
+		bracket.GroupID, err = h.Storage.CreateOrderGroup(tx, *bracket)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create bracket group: %w", err)
 		}
+		order.GroupID = &bracket.GroupID
+	}
 
-		// determine trade price
-		var tradePrice int64
-		if newOrder.OrderType == types.MARKET {
-			// market orders always execute at the counterparty's price
-			if matchingOrder.Price == nil {
-				slog.Error("Matching order has no price", "orderID", matchingOrder.OrderID)
-				continue
+	orderID, err := h.Storage.PlaceOrder(tx, *order)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to place order in database: %w", err)
+	}
+
+	order.OrderID = orderID
+	slog.Info("Processing order", "order_id", orderID)
+
+	if order.Status == types.REJECTED {
+		slog.Info("Order rejected", "order_id", orderID, "reason", order.Reason)
+	} else if order.IsStop() {
+		// stop orders wait in the trigger book, the last price may already cross them
+		book.AddStop(order)
+	} else if order.Dark {
+		// dark orders wait in the dark book, they only trade at the midpoint below
+		book.AddDark(order)
+	} else {
+		// process order for matching
+		trades, selfTrades, err = h.processOrder(tx, book, order)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to process order: %w", err)
+		}
+	}
+
+	// trades of this order may trigger stop orders, the order itself may be one
+	triggeredTrades, triggeredSelfTrades, err := h.triggerStops(tx, book)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to trigger stop orders: %w", err)
+	}
+	for _, trade := range triggeredTrades {
+		if trade.BuyOrderID == order.OrderID || trade.SellOrderID == order.OrderID {
+			trades = append(trades, trade)
+		}
+	}
+	for _, selfTrade := range triggeredSelfTrades {
+		if selfTrade.OrderID == order.OrderID || selfTrade.RestingOrderID == order.OrderID {
+			selfTrades = append(selfTrades, selfTrade)
+		}
+	}
+
+	// a dark immediate or cancel order only gets the one chance to cross the dark book
+	if _, ok := book.GetDark(order.OrderID); ok && order.TimeInForce == types.IOC {
+		book.RemoveDark(order.OrderID)
+		order.Status = types.CANCELLED
+		if err = h.Storage.MarkOrderCancelled(tx, order.OrderID, ""); err != nil {
+			return nil, nil, fmt.Errorf("failed to update order %d: %w", order.OrderID, err)
+		}
+	}
+
+	// commit the transaction if everything succeeded
+	if err = tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return trades, selfTrades, nil
+}
+
+// cancelOrder cancels an open or partially filled order and takes it out of the book
+func (h *OrderHandler) cancelOrder(book *orderbook.OrderBook, orderID int64) (err error) {
+	tx, err := h.Storage.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// the book is changed before the transaction commits, rebuild it on failure
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			h.loadBook(book)
+			panic(r)
+		} else if err != nil {
+			tx.Rollback()
+			if err := h.loadBook(book); err != nil {
+				slog.Error("Failed to reload order book", "error", err)
 			}
-			tradePrice = *matchingOrder.Price
-		} else if matchingOrder.OrderType == types.MARKET {
-			// if a limit order matching against a market order, use new order price
-			if newOrder.Price == nil {
-				slog.Error("New limit order has no price", "orderID", newOrder.OrderID)
-				continue
+		}
+	}()
+
+	// lock the order so a concurrent instance can't trade it while it is cancelled
+	order, err := h.Storage.GetOrderStatusTx(tx, orderID)
+	if errors.Is(err, storage.ErrOrderNotFound) {
+		return errOrderNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fetch order: %w", err)
+	}
+
+	switch order.Status {
+	case types.FILLED:
+		return errOrderFilled
+	case types.CANCELLED:
+		return errOrderCancelled
+	case types.EXPIRED:
+		return errOrderExpired
+	case types.REJECTED:
+		return errOrderRejected
+	case types.OPEN, types.PARTIAL, types.TRIGGERED:
+		// proceed with cancellation
+	default:
+		return errInvalidOrderStatus
+	}
+
+	if err = h.Storage.MarkOrderCancelled(tx, orderID, ""); err != nil {
+		return fmt.Errorf("failed to cancel order: %w", err)
+	}
+
+	book.Remove(orderID)
+	book.RemoveStop(orderID)
+	book.RemoveDark(orderID)
+
+	// the order may have set the price of pegged orders, which may trade and trigger stops
+	if _, _, err = h.triggerStops(tx, book); err != nil {
+		return fmt.Errorf("failed to trigger stop orders: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// processOrder matches the new order against the book and returns the executed trades
+// and the self trades that were prevented
+func (h *OrderHandler) processOrder(tx storage.Tx, book *orderbook.OrderBook, newOrder *types.Order) ([]types.Trade, []types.SelfTrade, error) {
+	var (
+		trades     []types.Trade
+		selfTrades []types.SelfTrade
+	)
+
+	// outside continuous trading orders only accumulate, they trade when the symbol uncrosses
+	if book.Phase != types.CONTINUOUS {
+		return nil, nil, h.holdOrder(tx, book, newOrder)
+	}
+
+	// determine opposite side for matching
+	oppositeSide := newOrder.Side.Opposite()
+
+	now := time.Now()
+
+	// market orders only trade within their protection price, a band is set from the touch
+	h.setProtectionPrice(book, newOrder)
+
+	// fill or kill orders must be fillable in full before any trade is written
+	if newOrder.TimeInForce == types.FOK && h.fillableQuantity(book, newOrder, now) < newOrder.Remaining {
+		slog.Info("Fill or kill order can't be fully filled, cancelling", "orderID", newOrder.OrderID)
+
+		newOrder.Status = types.CANCELLED
+		if err := h.Storage.UpdateOrder(tx, *newOrder); err != nil {
+			return nil, nil, fmt.Errorf("failed to cancel fill or kill order %d: %w", newOrder.OrderID, err)
+		}
+		return nil, nil, nil
+	}
+
+	// an order with a minimum quantity doesn't trade unless that much trades at once, it
+	// goes on as if the book didn't cross
+	minimum := newOrder.MinFill()
+	short := minimum > 0 && h.fillableQuantity(book, newOrder, now) < minimum
+
+	algorithm := h.matchingAlgorithm(book)
+	spent := false
+	// levels where every resting order asks for more than the new order can trade
+	skipped := make(map[int64]bool)
+	var traded int64
+
+	// walk the opposite side best price first, the symbol's algorithm shares each level
+	// among the orders resting at it
+	// self-trade prevention may cancel the new order before it is filled
+	for !short && newOrder.Remaining > 0 && newOrder.Status != types.CANCELLED {
+		if newOrder.HasQuoteQuantity() && newOrder.QuoteRemaining == 0 {
+			spent = true
+			break
+		}
+
+		level := nextLevel(book, oppositeSide, skipped)
+		if level == nil {
+			break
+		}
+
+		// levels are sorted, so once the best price doesn't match nothing behind it will
+		if !h.canMatch(newOrder, level.Front()) {
+			break
+		}
+
+		// a runaway price halts the symbol instead of trading
+		if h.breaksCircuit(book, level.Price) {
+			if err := h.tripCircuitBreaker(tx, book, level.Price); err != nil {
+				return nil, nil, err
 			}
-			tradePrice = *newOrder.Price
-		} else {
-			// both are limit orders, use the existing order's price
-			if matchingOrder.Price == nil {
-				slog.Error("Matching limit order has no price", "orderID", matchingOrder.OrderID)
-				continue
+			break
+		}
+
+		// the sweeper may not have caught up with expired orders yet
+		expired := false
+		for _, resting := range level.Orders() {
+			if resting.Expired(now) {
+				if err := h.expireOrder(tx, book, resting); err != nil {
+					return nil, nil, err
+				}
+				expired = true
 			}
-			tradePrice = *matchingOrder.Price
+		}
+		if expired {
+			continue
 		}
 
-		// create trade
-		trade := types.Trade{
-			Symbol:    newOrder.Symbol,
-			Price:     tradePrice,
-			Quantity:  tradeQuantity,
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
+		// an order spending a quote amount only takes what the amount still buys at this level
+		quantity := newOrder.Remaining
+		if newOrder.HasQuoteQuantity() {
+			// an amount too large to count buys all the order still needs
+			if affordable, ok := affordableQuantity(book, newOrder, level.Price); ok {
+				quantity = min(quantity, affordable)
+			}
+			if quantity == 0 {
+				spent = true
+				break
+			}
 		}
 
-		if newOrder.Side == types.BUY {
-			trade.BuyOrderID = newOrder.OrderID
-			trade.SellOrderID = matchingOrder.OrderID
-		} else {
-			trade.BuyOrderID = matchingOrder.OrderID
-			trade.SellOrderID = newOrder.OrderID
+		// resting orders with a minimum quantity keep their place when their share is too small
+		allocations := allocate(algorithm, level.Orders(), quantity)
+		if len(allocations) == 0 {
+			skipped[level.Price] = true
+			continue
 		}
 
-		_, err = h.Storage.CreateTrade(tx, trade)
-		if err != nil {
-			slog.Error("Failed to create trade", "error", err)
-			return nil, fmt.Errorf("failed to create trade: %w", err)
+		for _, allocation := range allocations {
+			// lock the resting order before trading it, another instance may have changed it
+			fresh, err := h.lockRestingOrder(tx, book, allocation.Order)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !fresh {
+				// the side was reloaded, share the level again
+				break
+			}
+
+			// orders of the same account never trade with each other
+			if newOrder.IsSelfTrade(allocation.Order) {
+				selfTrade, err := h.preventSelfTrade(tx, book, newOrder, allocation.Order)
+				if err != nil {
+					return nil, nil, err
+				}
+				selfTrades = append(selfTrades, selfTrade)
+
+				// the level or the new order changed, share the level again
+				break
+			}
+
+			// trades execute at the resting order's price, only priced limit orders rest in the book
+			trade, err := h.executeTrade(tx, book, newOrder, allocation.Order, allocation.Quantity, *allocation.Order.Price)
+			if err != nil {
+				return nil, nil, err
+			}
+			trades = append(trades, trade)
+			traded += allocation.Quantity
 		}
+	}
 
+	// the book changed under the minimum quantity check, rerun the order against a reloaded book
+	if traded > 0 && traded < minimum {
+		return nil, nil, fmt.Errorf("%w: order %d traded below its minimum quantity", storage.ErrWriteConflict, newOrder.OrderID)
+	}
+
+	if newOrder.Remaining <= 0 || newOrder.Status == types.CANCELLED {
+		return trades, selfTrades, nil
+	}
+
+	if spent && newOrder.Remaining < newOrder.Quantity {
+		return trades, selfTrades, h.finishQuoteOrder(tx, newOrder)
+	}
+
+	// the book changed under the fill or kill check, rerun the order against a reloaded book
+	if newOrder.TimeInForce == types.FOK {
+		return nil, nil, fmt.Errorf("%w: fill or kill order %d found a stale book", storage.ErrWriteConflict, newOrder.OrderID)
+	}
+
+	// limit orders rest in the book with whatever is left unless their time in force forbids it
+	if !newOrder.IsMarket() && newOrder.TimeInForce != types.IOC {
+		// an iceberg traded its whole quantity while matching, it only shows a slice once resting
+		if newOrder.IsIceberg() {
+			newOrder.VisibleRemaining = min(newOrder.DisplayQuantity, newOrder.Remaining)
+			if err := h.Storage.UpdateOrderVisible(tx, *newOrder, false); err != nil {
+				return nil, nil, fmt.Errorf("failed to update shown quantity of order %d: %w", newOrder.OrderID, err)
+			}
+		}
+
+		book.Add(newOrder)
+		return trades, selfTrades, nil
+	}
+
+	// for market and immediate or cancel orders that couldn't be fully filled, mark them as cancelled
+	slog.Warn("Order partially filled - remaining quantity will be cancelled",
+		"orderID", newOrder.OrderID,
+		"remaining", newOrder.Remaining,
+		"original", newOrder.Quantity)
+
+	if book.Phase == types.HALTED {
+		newOrder.Reason = "symbol was halted by its circuit breaker"
+	} else if short {
+		newOrder.Reason = fmt.Sprintf("min quantity %s can't be filled at once", decimal.New(minimum, book.QuantityScale))
+	} else if best := book.BestLevel(oppositeSide); best != nil && newOrder.ProtectionPrice != nil && !h.canMatch(newOrder, best.Front()) {
+		newOrder.Reason = fmt.Sprintf("market order protection price %s reached, next price is %s",
+			decimal.New(*newOrder.ProtectionPrice, book.PriceScale), decimal.New(best.Price, book.PriceScale))
+	}
+
+	newOrder.Status = types.CANCELLED
+	if err := h.Storage.MarkOrderCancelled(tx, newOrder.OrderID, newOrder.Reason); err != nil {
+		slog.Error("Failed to update order status", "error", err)
+		return nil, nil, fmt.Errorf("failed to update order %d: %w", newOrder.OrderID, err)
+	}
+
+	return trades, selfTrades, nil
+}
+
+// executeTrade trades quantity between the incoming order and a resting order at price and
+// persists both orders. When an auction uncrosses the incoming order rests in the book too.
+func (h *OrderHandler) executeTrade(tx storage.Tx, book *orderbook.OrderBook, newOrder, matchingOrder *types.Order, tradeQuantity int64, tradePrice int64) (types.Trade, error) {
+	// create trade
+	trade := types.Trade{
+		Symbol:        newOrder.Symbol,
+		Price:         tradePrice,
+		Quantity:      tradeQuantity,
+		PriceScale:    book.PriceScale,
+		QuantityScale: book.QuantityScale,
+		Venue:         types.LIT,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+
+	if newOrder.Side == types.BUY {
+		trade.BuyOrderID = newOrder.OrderID
+		trade.SellOrderID = matchingOrder.OrderID
+	} else {
+		trade.BuyOrderID = matchingOrder.OrderID
+		trade.SellOrderID = newOrder.OrderID
+	}
+
+	tradeID, err := h.Storage.CreateTrade(tx, trade)
+	if err != nil {
+		slog.Error("Failed to create trade", "error", err)
+		return trade, fmt.Errorf("failed to create trade: %w", err)
+	}
+	trade.TradeID = tradeID
+	book.SetLastPrice(trade.Price)
+
+	_, newResting := book.Get(newOrder.OrderID)
+	newRefilled := false
+	if newResting {
+		newRefilled = book.Fill(newOrder, tradeQuantity)
+	} else {
 		newOrder.Remaining -= tradeQuantity
-		matchingOrder.Remaining -= tradeQuantity
+		spendQuote(newOrder, tradePrice, tradeQuantity)
+	}
+	refilled := book.Fill(matchingOrder, tradeQuantity)
 
-		h.updateOrderStatus(newOrder)
-		h.updateOrderStatus(matchingOrder)
+	h.updateOrderStatus(newOrder)
+	h.updateOrderStatus(matchingOrder)
 
-		// update orders in db
-		if err := h.Storage.UpdateOrder(tx, newOrder.OrderID, newOrder.Remaining, newOrder.Status); err != nil {
-			slog.Error("Failed to update new order", "error", err)
-			return nil, fmt.Errorf("failed to update order %d: %w", newOrder.OrderID, err)
+	// update orders in db
+	if err := h.Storage.UpdateOrder(tx, *newOrder); err != nil {
+		slog.Error("Failed to update new order", "error", err)
+		return trade, fmt.Errorf("failed to update order %d: %w", newOrder.OrderID, err)
+	}
+
+	if err := h.Storage.UpdateOrder(tx, *matchingOrder); err != nil {
+		slog.Error("Failed to update matching order", "error", err)
+		return trade, fmt.Errorf("failed to update matching order %d: %w", matchingOrder.OrderID, err)
+	}
+
+	if matchingOrder.IsIceberg() {
+		if err := h.Storage.UpdateOrderVisible(tx, *matchingOrder, refilled); err != nil {
+			return trade, fmt.Errorf("failed to update shown quantity of order %d: %w", matchingOrder.OrderID, err)
+		}
+	}
+	if newResting && newOrder.IsIceberg() {
+		if err := h.Storage.UpdateOrderVisible(tx, *newOrder, newRefilled); err != nil {
+			return trade, fmt.Errorf("failed to update shown quantity of order %d: %w", newOrder.OrderID, err)
 		}
+	}
 
-		if err := h.Storage.UpdateOrder(tx, matchingOrder.OrderID, matchingOrder.Remaining, matchingOrder.Status); err != nil {
-			slog.Error("Failed to update matching order", "error", err)
-			return nil, fmt.Errorf("failed to update matching order %d: %w", matchingOrder.OrderID, err)
+	// a fill on a grouped order acts on the rest of its group in the same transaction
+	for _, order := range []*types.Order{newOrder, matchingOrder} {
+		if err := h.fillGroup(tx, book, order, tradeQuantity); err != nil {
+			return trade, err
 		}
+	}
+
+	slog.Info("Trade executed",
+		"symbol", trade.Symbol,
+		"price", trade.Price,
+		"quantity", trade.Quantity,
+		"buy_order", trade.BuyOrderID,
+		"sell_order", trade.SellOrderID,
+		"new_order_type", newOrder.OrderType,
+		"matching_order_type", matchingOrder.OrderType)
+
+	return trade, nil
+}
+
+// matchingAlgorithm returns the algorithm configured for a book's symbol, pro-rata shares
+// count the instrument's lots
+func (h *OrderHandler) matchingAlgorithm(book *orderbook.OrderBook) matching.Algorithm {
+	settings := h.Engine.Symbol(book.Symbol)
 
-		trades = append(trades, trade)
+	switch settings.Matching {
+	case config.MatchingProRata:
+		return matching.ProRata{MinAllocation: settings.MinAllocation, LotSize: book.LotSize}
+	case config.MatchingHybrid:
+		return matching.Hybrid{MinAllocation: settings.MinAllocation, LotSize: book.LotSize}
+	default:
+		return matching.FIFO{}
+	}
+}
 
-		slog.Info("Trade executed",
-			"symbol", trade.Symbol,
-			"price", trade.Price,
-			"quantity", trade.Quantity,
-			"buy_order", trade.BuyOrderID,
-			"sell_order", trade.SellOrderID,
-			"new_order_type", newOrder.OrderType,
-			"matching_order_type", matchingOrder.OrderType)
+// fillableQuantity returns how much of the order could trade against the book right now,
+// capped at its remaining quantity
+func (h *OrderHandler) fillableQuantity(book *orderbook.OrderBook, order *types.Order, now time.Time) int64 {
+	oppositeSide := order.Side.Opposite()
 
-		// if new order is fully filled, break
-		if newOrder.Remaining <= 0 {
+	var fillable int64
+	for _, level := range book.Levels(oppositeSide) {
+		if !h.canMatch(order, level.Front()) || h.breaksCircuit(book, level.Price) {
 			break
 		}
+
+		for _, resting := range level.Orders() {
+			if resting.Expired(now) {
+				continue
+			}
+
+			// an order of the same account is cancelled under cancel_oldest, any other
+			// mode stops the fill there
+			if order.IsSelfTrade(resting) {
+				if order.STPMode == types.CANCEL_OLDEST {
+					continue
+				}
+				return fillable
+			}
+
+			// a resting order asking for more than is left to fill keeps out of the match
+			if resting.MinFill() > order.Remaining-fillable {
+				continue
+			}
+
+			fillable += resting.Remaining
+			if fillable >= order.Remaining {
+				return order.Remaining
+			}
+		}
 	}
 
-	// for market orders that couldn't be fully filled, mark them as cancelled
-	if newOrder.OrderType == types.MARKET && newOrder.Remaining > 0 {
-		slog.Warn("Market order partially filled - remaining quantity will be cancelled",
-			"orderID", newOrder.OrderID,
-			"remaining", newOrder.Remaining,
-			"original", newOrder.Quantity)
+	return fillable
+}
 
-		newOrder.Status = types.CANCELLED
-		if err := h.Storage.UpdateOrder(tx, newOrder.OrderID, newOrder.Remaining, newOrder.Status); err != nil {
-			slog.Error("Failed to update market order status", "error", err)
-			return nil, fmt.Errorf("failed to update market order %d: %w", newOrder.OrderID, err)
+// lockRestingOrder locks the stored row of a resting order and reports whether the book
+// agrees with it. When it doesn't the side is reloaded from storage under lock.
+func (h *OrderHandler) lockRestingOrder(tx storage.Tx, book *orderbook.OrderBook, order *types.Order) (bool, error) {
+	stored, err := h.Storage.GetOrderStatusTx(tx, order.OrderID)
+	switch {
+	case err == nil:
+		if stored.Status == order.Status && stored.Remaining == order.Remaining && stored.VisibleRemaining == order.VisibleRemaining {
+			return true, nil
 		}
+	case errors.Is(err, storage.ErrOrderNotFound):
+		// gone from storage, the reload below drops it
+	default:
+		return false, fmt.Errorf("failed to lock order %d: %w", order.OrderID, err)
 	}
 
-	return trades, nil
+	slog.Warn("Order book is stale, reloading side from storage",
+		"symbol", book.Symbol,
+		"side", order.Side,
+		"order_id", order.OrderID)
+
+	side := order.Side
+	orders, err := h.Storage.GetMatchingOrdersTx(tx, book.Symbol, &side)
+	if err != nil {
+		return false, fmt.Errorf("failed to reload %s side of %s: %w", side, book.Symbol, err)
+	}
+	book.ResetSide(side, orders)
+
+	return false, nil
 }
 
-// check if two orders can be matched based on price
-func (h *OrderHandler) canMatch(newOrder *types.Order, existingOrder *types.Order) bool {
-	// Market orders can always match with limit orders
-	if newOrder.OrderType == types.MARKET && (existingOrder.OrderType == types.LIMIT || existingOrder.OrderType == types.MARKET) {
-		return true
+// retryOnConflict reruns fn while its transaction loses against a concurrent one
+func retryOnConflict(fn func() error) error {
+	var err error
+	for attempt := 1; attempt <= maxConflictRetries; attempt++ {
+		if err = fn(); !errors.Is(err, storage.ErrWriteConflict) {
+			return err
+		}
+		slog.Warn("Transaction conflict, retrying", "attempt", attempt, "error", err)
 	}
+	return err
+}
 
-	// Both market orders can match
-	if newOrder.OrderType == types.MARKET && existingOrder.OrderType == types.MARKET {
-		return true
+// check if two orders can be matched based on price
+func (h *OrderHandler) canMatch(newOrder *types.Order, existingOrder *types.Order) bool {
+	// Market and triggered stop orders match at any price up to their protection price
+	if newOrder.IsMarket() {
+		if newOrder.ProtectionPrice == nil || existingOrder.Price == nil {
+			return true
+		}
+		if newOrder.Side == types.BUY {
+			return *existingOrder.Price <= *newOrder.ProtectionPrice
+		}
+		return *existingOrder.Price >= *newOrder.ProtectionPrice
 	}
 
 	// For limit orders, compare prices
@@ -177,6 +698,9 @@ func (h *OrderHandler) updateOrderStatus(order *types.Order) {
 		order.Status = types.FILLED
 	case order.Remaining < order.Quantity:
 		order.Status = types.PARTIAL
+	case order.IsStop():
+		// a stop order only gets here once triggered
+		order.Status = types.TRIGGERED
 	default:
 		order.Status = types.OPEN
 	}
@@ -0,0 +1,133 @@
+package order
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/orderbook"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/storage"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+)
+
+// expirySweepInterval is how often resting DAY and GTD orders are checked for expiry
+const expirySweepInterval = time.Second
+
+// sweepExpiredOrders expires due orders of every loaded book and moves symbols whose
+// trading phase is over to the next one, it also uncrosses the batches that are due. It
+// runs until the handler is closed.
+func (h *OrderHandler) sweepExpiredOrders() {
+	defer close(h.sweeperDone)
+
+	ticker := time.NewTicker(expirySweepInterval)
+	defer ticker.Stop()
+
+	batchTicker := time.NewTicker(h.Engine.BatchSweepInterval)
+	defer batchTicker.Stop()
+
+	for {
+		select {
+		case <-h.stopSweeper:
+			return
+		case now := <-batchTicker.C:
+			h.runBatches(now)
+		case now := <-ticker.C:
+			for _, symbol := range h.loadedSymbols() {
+				err := h.submit(symbol, func(book *orderbook.OrderBook) error {
+					return retryOnConflict(func() error {
+						if err := h.expireOrders(book, now); err != nil {
+							return err
+						}
+						return h.advancePhase(book, now)
+					})
+				})
+				if err != nil {
+					slog.Error("Failed to expire orders", "symbol", symbol, "error", err)
+				}
+			}
+		}
+	}
+}
+
+// loadedSymbols returns the symbols whose book is held in memory
+func (h *OrderHandler) loadedSymbols() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	symbols := make([]string, 0, len(h.books))
+	for symbol := range h.books {
+		symbols = append(symbols, symbol)
+	}
+	return symbols
+}
+
+// expireOrders expires every resting order of the book that is due at now
+func (h *OrderHandler) expireOrders(book *orderbook.OrderBook, now time.Time) (err error) {
+	expired := book.Expired(now)
+	if len(expired) == 0 {
+		return nil
+	}
+
+	tx, err := h.Storage.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// the due orders were taken off the expiry queue, rebuild the book if they stay open
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			h.loadBook(book)
+			panic(r)
+		} else if err != nil {
+			tx.Rollback()
+			if err := h.loadBook(book); err != nil {
+				slog.Error("Failed to reload order book", "error", err)
+			}
+		}
+	}()
+
+	for _, order := range expired {
+		if err = h.expireOrder(tx, book, order); err != nil {
+			return err
+		}
+	}
+
+	// the orders may have set the price of pegged orders, which may trade and trigger stops
+	if _, _, err = h.triggerStops(tx, book); err != nil {
+		return fmt.Errorf("failed to trigger stop orders: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// expireOrder marks a resting order expired and takes it out of the book
+func (h *OrderHandler) expireOrder(tx storage.Tx, book *orderbook.OrderBook, order *types.Order) error {
+	book.Remove(order.OrderID)
+	book.RemoveStop(order.OrderID)
+	book.RemoveDark(order.OrderID)
+
+	// another instance may have filled or cancelled it already
+	stored, err := h.Storage.GetOrderStatusTx(tx, order.OrderID)
+	if err != nil {
+		return fmt.Errorf("failed to lock order %d: %w", order.OrderID, err)
+	}
+	if stored.Status != types.OPEN && stored.Status != types.PARTIAL && stored.Status != types.TRIGGERED {
+		return nil
+	}
+
+	order.Remaining = stored.Remaining
+	order.Status = types.EXPIRED
+
+	if err := h.Storage.UpdateOrder(tx, *order); err != nil {
+		return fmt.Errorf("failed to expire order %d: %w", order.OrderID, err)
+	}
+
+	slog.Info("Order expired", "order_id", order.OrderID, "time_in_force", order.TimeInForce, "expire_at", order.ExpireAt)
+
+	return nil
+}
@@ -0,0 +1,325 @@
+package order
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/orderbook"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/storage"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/utils/response"
+	"github.com/go-playground/validator/v10"
+)
+
+func (h *OrderHandler) PlaceOrderGroup(w http.ResponseWriter, r *http.Request) {
+	var groupBody types.PlaceOrderGroupRequest
+	err := json.NewDecoder(r.Body).Decode(&groupBody)
+	if errors.Is(err, io.EOF) {
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("empty body")))
+		return
+	}
+	if err != nil {
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralErrorString("invalid request body"))
+		return
+	}
+
+	if err := validator.New().Struct(groupBody); err != nil {
+		validateErrors := err.(validator.ValidationErrors)
+		response.WriteJson(w, http.StatusBadRequest, response.ValidationError(validateErrors))
+		return
+	}
+
+	now := time.Now()
+	if err := h.validateOrderGroup(&groupBody, now); err != nil {
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+		return
+	}
+
+	instrument, err := h.tradingInstrument(groupBody.Orders[0].Symbol)
+	switch {
+	case errors.Is(err, errUnknownSymbol), errors.Is(err, errSymbolInactive):
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+		return
+	case err != nil:
+		slog.Error("Failed to get instrument", "error", err)
+		response.WriteJson(w, http.StatusInternalServerError, response.GeneralErrorString("failed to place order group"))
+		return
+	}
+
+	group := &types.OrderGroup{
+		Type:   groupBody.Type,
+		OnFill: groupBody.OnFill,
+	}
+	for i := range groupBody.Orders {
+		order, err := h.newOrder(&groupBody.Orders[i], instrument, now)
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("order %d: %w", i+1, err)))
+			return
+		}
+		group.Orders = append(group.Orders, order)
+	}
+
+	// both orders live on the symbol's sequencer, the response is built from copies taken there
+	var (
+		placed []types.Order
+		trades []types.Trade
+	)
+	drafts := make([]types.Order, len(group.Orders))
+	for i, order := range group.Orders {
+		drafts[i] = *order
+	}
+	err = h.submit(groupBody.Orders[0].Symbol, func(book *orderbook.OrderBook) error {
+		return retryOnConflict(func() error {
+			for i, order := range group.Orders {
+				*order = drafts[i]
+			}
+
+			var err error
+			trades, err = h.placeOrderGroup(book, group)
+
+			placed = placed[:0]
+			for _, order := range group.Orders {
+				placed = append(placed, *order)
+			}
+			return err
+		})
+	})
+	if err != nil {
+		slog.Error("Failed to place order group", "error", err)
+		response.WriteJson(w, http.StatusInternalServerError, response.GeneralErrorString("failed to place order group"))
+		return
+	}
+
+	orders := make([]map[string]any, 0, len(placed))
+	for _, order := range placed {
+		data := map[string]any{
+			"order_id": order.OrderID,
+			"status":   order.Status,
+		}
+		if order.Reason != "" {
+			data["reason"] = order.Reason
+		}
+		orders = append(orders, data)
+	}
+
+	response.WriteJson(w, http.StatusOK, map[string]any{
+		"message": "order group placed successfully",
+		"data": map[string]any{
+			"group_id": group.GroupID,
+			"orders":   orders,
+			"trades":   trades,
+		},
+	})
+}
+
+// validateOrderGroup validates every order of the group, they have to share a symbol and an account
+func (h *OrderHandler) validateOrderGroup(req *types.PlaceOrderGroupRequest, now time.Time) error {
+	if req.OnFill == "" {
+		req.OnFill = types.FILL_CANCELS
+	}
+
+	for i := range req.Orders {
+		order := &req.Orders[i]
+		if order.Symbol != req.Orders[0].Symbol || order.AccountID != req.Orders[0].AccountID {
+			return fmt.Errorf("orders of a group must have the same symbol and account_id")
+		}
+		if order.TakeProfit != nil || order.StopLoss != nil {
+			return fmt.Errorf("take_profit and stop_loss are not allowed on orders of a group")
+		}
+		if order.Dark {
+			return fmt.Errorf("dark orders are not allowed in a group")
+		}
+		if err := h.validateOrder(order, now); err != nil {
+			return fmt.Errorf("order %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func (h *OrderHandler) GetOrderGroup(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("groupId")
+	groupID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid group id")))
+		return
+	}
+
+	group, err := h.Storage.GetOrderGroup(groupID)
+	if errors.Is(err, storage.ErrGroupNotFound) {
+		response.WriteJson(w, http.StatusNotFound, response.GeneralError(err))
+		return
+	}
+	if err != nil {
+		response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(fmt.Errorf("failed to get order group: %w", err)))
+		return
+	}
+
+	response.WriteJson(w, http.StatusOK, map[string]any{
+		"message": "order group fetched successfully",
+		"data":    group,
+	})
+}
+
+// placeOrderGroup persists a group with its orders and matches them one after the
+// other within a single transaction, it returns the trades of the group's orders
+func (h *OrderHandler) placeOrderGroup(book *orderbook.OrderBook, group *types.OrderGroup) (trades []types.Trade, err error) {
+	tx, err := h.Storage.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			h.loadBook(book)
+			panic(r)
+		} else if err != nil {
+			tx.Rollback()
+			if err := h.loadBook(book); err != nil {
+				slog.Error("Failed to reload order book", "error", err)
+			}
+		}
+	}()
+
+	group.GroupID, err = h.Storage.CreateOrderGroup(tx, *group)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create order group: %w", err)
+	}
+
+	// every order is stored before any of them trades, so a fill finds its siblings
+	for _, order := range group.Orders {
+		order.GroupID = &group.GroupID
+
+		h.checkPhase(book, order)
+		h.startPeg(book, order)
+		h.checkPostOnly(book, order)
+		if err = h.checkPriceCollars(book, order, time.Now()); err != nil {
+			return nil, err
+		}
+		h.startTrailing(book, order)
+		h.sizeQuoteOrder(book, order)
+
+		order.OrderID, err = h.Storage.PlaceOrder(tx, *order)
+		if err != nil {
+			return nil, fmt.Errorf("failed to place order in database: %w", err)
+		}
+	}
+
+	slog.Info("Processing order group", "group_id", group.GroupID, "type", group.Type)
+
+	for i, order := range group.Orders {
+		// a fill of an earlier order may have cancelled or reduced this one
+		if i > 0 {
+			stored, err := h.Storage.GetOrderStatusTx(tx, order.OrderID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to lock order %d: %w", order.OrderID, err)
+			}
+			order.Quantity, order.Remaining, order.Status, order.Reason = stored.Quantity, stored.Remaining, stored.Status, stored.Reason
+			if order.IsIceberg() {
+				order.VisibleRemaining = min(order.DisplayQuantity, order.Remaining)
+			}
+		}
+
+		switch {
+		case order.Status == types.REJECTED:
+			slog.Info("Order rejected", "order_id", order.OrderID, "reason", order.Reason)
+		case order.Status == types.CANCELLED:
+		case order.IsStop():
+			book.AddStop(order)
+		default:
+			orderTrades, _, err := h.processOrder(tx, book, order)
+			if err != nil {
+				return nil, fmt.Errorf("failed to process order: %w", err)
+			}
+			trades = append(trades, orderTrades...)
+		}
+	}
+
+	triggeredTrades, _, err := h.triggerStops(tx, book)
+	if err != nil {
+		return nil, fmt.Errorf("failed to trigger stop orders: %w", err)
+	}
+	for _, trade := range triggeredTrades {
+		for _, order := range group.Orders {
+			if trade.BuyOrderID == order.OrderID || trade.SellOrderID == order.OrderID {
+				trades = append(trades, trade)
+				break
+			}
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return trades, nil
+}
+
+// fillGroup applies a fill of quantity on a grouped order to the other working orders of
+// its group, they are cancelled or, when the group reduces, shrink by the filled quantity.
+// A fill of a bracket's parent sizes its legs instead, the legs only cancel each other.
+func (h *OrderHandler) fillGroup(tx storage.Tx, book *orderbook.OrderBook, order *types.Order, quantity int64) error {
+	if order.GroupID == nil {
+		return nil
+	}
+
+	group, err := h.Storage.GetOrderGroupTx(tx, *order.GroupID)
+	if err != nil {
+		return fmt.Errorf("failed to lock order group %d: %w", *order.GroupID, err)
+	}
+
+	bracket := group.Type == types.BRACKET
+	if bracket && order.OrderID == group.Orders[0].OrderID {
+		return h.fillBracketParent(tx, book, group, order, quantity)
+	}
+
+	for i, stored := range group.Orders {
+		if stored.OrderID == order.OrderID || bracket && i == 0 {
+			continue
+		}
+		switch stored.Status {
+		case types.OPEN, types.PARTIAL, types.TRIGGERED:
+		default:
+			continue
+		}
+
+		// act on the book's copy when the sibling is resting or waiting for its trigger
+		sibling := stored
+		if resting, ok := book.Get(stored.OrderID); ok {
+			sibling = resting
+		} else if pending, ok := book.GetStop(stored.OrderID); ok {
+			sibling = pending
+		}
+
+		if group.OnFill == types.FILL_REDUCES && sibling.Remaining > quantity {
+			book.Reduce(sibling, quantity)
+			sibling.Quantity -= quantity
+			if err := h.Storage.AmendOrder(tx, *sibling, false); err != nil {
+				return fmt.Errorf("failed to reduce order %d: %w", sibling.OrderID, err)
+			}
+
+			slog.Info("Grouped order reduced", "group_id", group.GroupID, "order_id", sibling.OrderID, "filled_order_id", order.OrderID, "quantity", quantity)
+			continue
+		}
+
+		book.Remove(sibling.OrderID)
+		book.RemoveStop(sibling.OrderID)
+
+		sibling.Status = types.CANCELLED
+		sibling.Reason = fmt.Sprintf("order %d of the %s group was filled", order.OrderID, group.Type)
+		if err := h.Storage.MarkOrderCancelled(tx, sibling.OrderID, sibling.Reason); err != nil {
+			return fmt.Errorf("failed to cancel order %d: %w", sibling.OrderID, err)
+		}
+
+		slog.Info("Grouped order cancelled", "group_id", group.GroupID, "order_id", sibling.OrderID, "filled_order_id", order.OrderID)
+	}
+
+	return nil
+}
@@ -0,0 +1,266 @@
+package order
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/decimal"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/orderbook"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/storage"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/utils/response"
+	"github.com/go-playground/validator/v10"
+)
+
+var (
+	errAlreadyHalted = errors.New("symbol is already halted")
+	errSymbolClosed  = errors.New("symbol is already closed")
+	errNotResumable  = errors.New("only halted or closed symbols can be resumed")
+	errSymbolHalted  = errors.New("symbol is halted or closed, only quantity reductions are accepted")
+)
+
+func (h *OrderHandler) GetSymbolStatus(w http.ResponseWriter, r *http.Request) {
+	symbol := r.PathValue("symbol")
+
+	status, err := h.Storage.GetSymbolStatus(symbol)
+	if err != nil {
+		response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(fmt.Errorf("failed to get symbol status: %w", err)))
+		return
+	}
+	status.Mode = h.matchingMode(symbol, status)
+	if status.Phase == types.CONTINUOUS || status.Phase == types.BATCH {
+		status.Phase = status.Mode
+	}
+
+	response.WriteJson(w, http.StatusOK, map[string]any{
+		"message": "symbol status fetched successfully",
+		"data":    status,
+	})
+}
+
+// HaltSymbol stops a trading symbol until it is resumed, working orders stay in the book
+func (h *OrderHandler) HaltSymbol(w http.ResponseWriter, r *http.Request) {
+	h.stopSymbol(w, r, types.HALTED)
+}
+
+// CloseSymbol ends trading in a symbol, it takes no new orders until it is resumed
+func (h *OrderHandler) CloseSymbol(w http.ResponseWriter, r *http.Request) {
+	h.stopSymbol(w, r, types.CLOSED)
+}
+
+func (h *OrderHandler) stopSymbol(w http.ResponseWriter, r *http.Request, phase types.TradingPhase) {
+	symbol := r.PathValue("symbol")
+
+	// the reason is optional, so is the body
+	var haltBody types.HaltSymbolRequest
+	if err := json.NewDecoder(r.Body).Decode(&haltBody); err != nil && !errors.Is(err, io.EOF) {
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralErrorString("invalid request body"))
+		return
+	}
+
+	if err := validator.New().Struct(haltBody); err != nil {
+		validateErrors := err.(validator.ValidationErrors)
+		response.WriteJson(w, http.StatusBadRequest, response.ValidationError(validateErrors))
+		return
+	}
+
+	status := types.SymbolStatus{Symbol: symbol, Phase: phase, Reason: haltBody.Reason}
+	err := h.submit(symbol, func(book *orderbook.OrderBook) error {
+		switch {
+		case book.Phase == types.CLOSED:
+			return errSymbolClosed
+		case phase == types.HALTED && book.Phase == types.HALTED:
+			return errAlreadyHalted
+		}
+		return retryOnConflict(func() error {
+			return h.setPhase(book, status)
+		})
+	})
+	switch {
+	case errors.Is(err, storage.ErrInstrumentNotFound):
+		response.WriteJson(w, http.StatusNotFound, response.GeneralError(storage.ErrInstrumentNotFound))
+		return
+	case errors.Is(err, errSymbolClosed), errors.Is(err, errAlreadyHalted):
+		response.WriteJson(w, http.StatusConflict, response.GeneralError(err))
+		return
+	case err != nil:
+		slog.Error("Failed to change trading phase", "symbol", symbol, "phase", phase, "error", err)
+		response.WriteJson(w, http.StatusInternalServerError, response.GeneralErrorString("failed to change trading phase"))
+		return
+	}
+
+	response.WriteJson(w, http.StatusOK, map[string]any{
+		"message": fmt.Sprintf("symbol %s successfully", phase),
+		"data":    status,
+	})
+}
+
+// ResumeSymbol puts a halted or closed symbol back to trading in its matching mode, orders
+// queued in a crossed book trade first as if an auction uncrossed
+func (h *OrderHandler) ResumeSymbol(w http.ResponseWriter, r *http.Request) {
+	symbol := r.PathValue("symbol")
+
+	var (
+		auction types.Auction
+		trades  []types.Trade
+		phase   types.TradingPhase
+	)
+	err := h.submit(symbol, func(book *orderbook.OrderBook) error {
+		if book.Phase != types.HALTED && book.Phase != types.CLOSED {
+			return errNotResumable
+		}
+		return retryOnConflict(func() error {
+			var err error
+			auction, trades, err = h.uncross(book)
+			phase = book.Phase
+			return err
+		})
+	})
+	switch {
+	case errors.Is(err, storage.ErrInstrumentNotFound):
+		response.WriteJson(w, http.StatusNotFound, response.GeneralError(storage.ErrInstrumentNotFound))
+		return
+	case errors.Is(err, errNotResumable):
+		response.WriteJson(w, http.StatusConflict, response.GeneralError(err))
+		return
+	case err != nil:
+		slog.Error("Failed to resume symbol", "symbol", symbol, "error", err)
+		response.WriteJson(w, http.StatusInternalServerError, response.GeneralErrorString("failed to resume symbol"))
+		return
+	}
+
+	data := map[string]any{
+		"symbol": symbol,
+		"phase":  phase,
+		"trades": trades,
+	}
+	if auction.IndicativePrice != nil {
+		data["auction"] = auction
+	}
+
+	response.WriteJson(w, http.StatusOK, map[string]any{
+		"message": "symbol resumed successfully",
+		"data":    data,
+	})
+}
+
+// setPhase moves a symbol into another trading phase, nothing trades on the way
+func (h *OrderHandler) setPhase(book *orderbook.OrderBook, status types.SymbolStatus) (err error) {
+	tx, err := h.Storage.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		} else if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = h.Storage.SetSymbolStatus(tx, status); err != nil {
+		return fmt.Errorf("failed to set trading phase: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	book.Phase, book.PhaseUntil = status.Phase, status.Until
+	slog.Info("Trading phase changed", "symbol", book.Symbol, "phase", status.Phase, "reason", status.Reason, "until", status.Until)
+
+	return nil
+}
+
+// advancePhase moves a symbol on once its phase is over: a circuit breaker halt reopens
+// with an auction call, which uncrosses when its time is up
+func (h *OrderHandler) advancePhase(book *orderbook.OrderBook, now time.Time) error {
+	if book.PhaseUntil == nil || now.Before(*book.PhaseUntil) {
+		return nil
+	}
+
+	switch book.Phase {
+	case types.HALTED:
+		until := now.Add(h.Engine.Symbol(book.Symbol).ReopeningAuction)
+		return h.setPhase(book, types.SymbolStatus{
+			Symbol: book.Symbol,
+			Phase:  types.AUCTION,
+			Reason: "reopening auction",
+			Until:  &until,
+		})
+	case types.AUCTION:
+		_, _, err := h.uncross(book)
+		return err
+	}
+
+	return nil
+}
+
+// holdOrder keeps an order from trading outside continuous trading. A priced order rests
+// in the book until the symbol uncrosses, any other is cancelled.
+func (h *OrderHandler) holdOrder(tx storage.Tx, book *orderbook.OrderBook, order *types.Order) error {
+	if !order.IsMarket() && order.TimeInForce != types.IOC && order.TimeInForce != types.FOK {
+		book.Add(order)
+		return nil
+	}
+
+	order.Status = types.CANCELLED
+	order.Reason = fmt.Sprintf("symbol is %s", book.Phase)
+	if book.Phase == types.BATCH {
+		order.Reason = "symbol matches in batches"
+	}
+	if err := h.Storage.MarkOrderCancelled(tx, order.OrderID, order.Reason); err != nil {
+		return fmt.Errorf("failed to cancel order %d: %w", order.OrderID, err)
+	}
+	return nil
+}
+
+// breaksCircuit reports whether a trade at price would move the symbol's price more than
+// its circuit breaker allows within the latest trades
+func (h *OrderHandler) breaksCircuit(book *orderbook.OrderBook, price int64) bool {
+	// the percent is validated when the configuration loads
+	bps, _ := h.Engine.Symbol(book.Symbol).CircuitBreakerPercent.Units(2)
+	if bps <= 0 {
+		return false
+	}
+
+	low, high, ok := book.PriceRange()
+	if !ok {
+		return false
+	}
+	low, high = min(low, price), max(high, price)
+
+	// the move is a whole number of units, it exceeds the threshold when it exceeds the
+	// threshold rounded down. A threshold beyond an int64 can't be exceeded.
+	threshold, ok := decimal.MulDiv(low, bps, 10000)
+	return ok && high-low > threshold
+}
+
+// tripCircuitBreaker halts a symbol whose next trade at price would break its circuit, it
+// reopens with an auction call after the symbol's halt duration
+func (h *OrderHandler) tripCircuitBreaker(tx storage.Tx, book *orderbook.OrderBook, price int64) error {
+	settings := h.Engine.Symbol(book.Symbol)
+	until := time.Now().Add(settings.HaltDuration)
+
+	status := types.SymbolStatus{
+		Symbol: book.Symbol,
+		Phase:  types.HALTED,
+		Reason: fmt.Sprintf("circuit breaker: a trade at %s would move the price more than %s%% within %d trades", decimal.New(price, book.PriceScale), *settings.CircuitBreakerPercent, settings.CircuitBreakerTrades),
+		Until:  &until,
+	}
+	if err := h.Storage.SetSymbolStatus(tx, status); err != nil {
+		return fmt.Errorf("failed to halt %s: %w", book.Symbol, err)
+	}
+	book.Phase, book.PhaseUntil = status.Phase, status.Until
+
+	slog.Warn("Circuit breaker tripped", "symbol", book.Symbol, "price", price, "reason", status.Reason, "until", until)
+
+	return nil
+}
@@ -0,0 +1,27 @@
+package order
+
+import (
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/orderbook"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+)
+
+// bid is a buy limit order for bookOf
+func bid(price, quantity int64) *types.Order {
+	return &types.Order{Side: types.BUY, OrderType: types.LIMIT, Price: &price, Quantity: quantity, Remaining: quantity}
+}
+
+// ask is a sell limit order for bookOf
+func ask(price, quantity int64) *types.Order {
+	return &types.Order{Side: types.SELL, OrderType: types.LIMIT, Price: &price, Quantity: quantity, Remaining: quantity}
+}
+
+// bookOf returns a book resting orders with ids 1, 2, ... in the order they are given,
+// which is their time priority too
+func bookOf(orders ...*types.Order) *orderbook.OrderBook {
+	book := orderbook.New("TEST")
+	for i, order := range orders {
+		order.OrderID = int64(i + 1)
+		book.Add(order)
+	}
+	return book
+}
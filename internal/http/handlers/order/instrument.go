@@ -0,0 +1,386 @@
+package order
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/decimal"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/orderbook"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/storage"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/utils/response"
+	"github.com/go-playground/validator/v10"
+)
+
+var (
+	errUnknownSymbol     = errors.New("unknown symbol")
+	errSymbolInactive    = errors.New("symbol is not active")
+	errInvalidInstrument = errors.New("invalid instrument")
+	errInstrumentInUse   = errors.New("instrument still has working orders")
+)
+
+func (h *OrderHandler) ListInstruments(w http.ResponseWriter, r *http.Request) {
+	instruments, err := h.Storage.ListInstruments()
+	if err != nil {
+		slog.Error("Failed to list instruments", "error", err)
+		response.WriteJson(w, http.StatusInternalServerError, response.GeneralErrorString("failed to list instruments"))
+		return
+	}
+
+	response.WriteJson(w, http.StatusOK, map[string]any{
+		"message": "instruments fetched successfully",
+		"data":    instruments,
+	})
+}
+
+func (h *OrderHandler) GetInstrument(w http.ResponseWriter, r *http.Request) {
+	symbol := r.PathValue("symbol")
+
+	instrument, err := h.Storage.GetInstrument(symbol)
+	if errors.Is(err, storage.ErrInstrumentNotFound) {
+		response.WriteJson(w, http.StatusNotFound, response.GeneralError(err))
+		return
+	}
+	if err != nil {
+		response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(fmt.Errorf("failed to get instrument: %w", err)))
+		return
+	}
+
+	response.WriteJson(w, http.StatusOK, map[string]any{
+		"message": "instrument fetched successfully",
+		"data":    instrument,
+	})
+}
+
+// CreateInstrument lists a new instrument, orders are taken for it right away when it is active
+func (h *OrderHandler) CreateInstrument(w http.ResponseWriter, r *http.Request) {
+	var instrumentBody types.CreateInstrumentRequest
+	err := json.NewDecoder(r.Body).Decode(&instrumentBody)
+	if errors.Is(err, io.EOF) {
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("empty body")))
+		return
+	}
+	if err != nil {
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralErrorString("invalid request body"))
+		return
+	}
+
+	if err := validator.New().Struct(instrumentBody); err != nil {
+		validateErrors := err.(validator.ValidationErrors)
+		response.WriteJson(w, http.StatusBadRequest, response.ValidationError(validateErrors))
+		return
+	}
+
+	instrument := types.Instrument{
+		Symbol:        instrumentBody.Symbol,
+		BaseAsset:     instrumentBody.BaseAsset,
+		QuoteAsset:    instrumentBody.QuoteAsset,
+		TickSize:      h.Engine.TickSize,
+		LotSize:       1,
+		PriceScale:    instrumentBody.PriceScale,
+		QuantityScale: instrumentBody.QuantityScale,
+		Status:        instrumentBody.Status,
+	}
+	if err := setInstrumentSizes(&instrument, instrumentBody.TickSize, instrumentBody.LotSize, instrumentBody.MinQuantity, instrumentBody.MaxQuantity); err != nil {
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+		return
+	}
+	if instrument.MinQuantity == 0 {
+		instrument.MinQuantity = instrument.LotSize
+	}
+	if instrument.Status == "" {
+		instrument.Status = types.ACTIVE
+	}
+
+	if err := validateInstrument(instrument); err != nil {
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+		return
+	}
+	if err := h.validateCollars(instrument); err != nil {
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+		return
+	}
+
+	err = h.Storage.CreateInstrument(instrument)
+	switch {
+	case errors.Is(err, storage.ErrInstrumentExists):
+		response.WriteJson(w, http.StatusConflict, response.GeneralError(err))
+		return
+	case err != nil:
+		slog.Error("Failed to create instrument", "symbol", instrument.Symbol, "error", err)
+		response.WriteJson(w, http.StatusInternalServerError, response.GeneralErrorString("failed to create instrument"))
+		return
+	}
+
+	slog.Info("Instrument created", "symbol", instrument.Symbol,
+		"tick_size", decimal.New(instrument.TickSize, instrument.PriceScale),
+		"lot_size", decimal.New(instrument.LotSize, instrument.QuantityScale))
+
+	// read back for the timestamps set by the database
+	created, err := h.Storage.GetInstrument(instrument.Symbol)
+	if err != nil {
+		response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(fmt.Errorf("failed to get instrument: %w", err)))
+		return
+	}
+
+	response.WriteJson(w, http.StatusOK, map[string]any{
+		"message": "instrument created successfully",
+		"data":    created,
+	})
+}
+
+// UpdateInstrument changes the settings of an instrument. Working orders keep their
+// price and quantity, the new sizes apply to new orders and amendments.
+func (h *OrderHandler) UpdateInstrument(w http.ResponseWriter, r *http.Request) {
+	symbol := r.PathValue("symbol")
+
+	var updateBody types.UpdateInstrumentRequest
+	err := json.NewDecoder(r.Body).Decode(&updateBody)
+	if errors.Is(err, io.EOF) {
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("empty body")))
+		return
+	}
+	if err != nil {
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralErrorString("invalid request body"))
+		return
+	}
+
+	if err := validator.New().Struct(updateBody); err != nil {
+		validateErrors := err.(validator.ValidationErrors)
+		response.WriteJson(w, http.StatusBadRequest, response.ValidationError(validateErrors))
+		return
+	}
+
+	// the book's tick and lot size change with the instrument, so the update runs on the symbol's sequencer
+	var updated *types.Instrument
+	err = h.submit(symbol, func(book *orderbook.OrderBook) error {
+		instrument, err := h.Storage.GetInstrument(symbol)
+		if err != nil {
+			return err
+		}
+		if err := applyInstrumentUpdate(instrument, updateBody); err != nil {
+			return err
+		}
+		if err := validateInstrument(*instrument); err != nil {
+			return err
+		}
+		if err := h.Storage.UpdateInstrument(*instrument); err != nil {
+			return fmt.Errorf("failed to update instrument %s: %w", symbol, err)
+		}
+
+		book.TickSize, book.LotSize = instrument.TickSize, instrument.LotSize
+		book.MinQuantity, book.MaxQuantity = instrument.MinQuantity, instrument.MaxQuantity
+		updated, err = h.Storage.GetInstrument(symbol)
+		return err
+	})
+	switch {
+	case errors.Is(err, storage.ErrInstrumentNotFound):
+		response.WriteJson(w, http.StatusNotFound, response.GeneralError(storage.ErrInstrumentNotFound))
+		return
+	case errors.Is(err, errInvalidInstrument):
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+		return
+	case err != nil:
+		slog.Error("Failed to update instrument", "symbol", symbol, "error", err)
+		response.WriteJson(w, http.StatusInternalServerError, response.GeneralErrorString("failed to update instrument"))
+		return
+	}
+
+	slog.Info("Instrument updated", "symbol", symbol, "status", updated.Status)
+
+	response.WriteJson(w, http.StatusOK, map[string]any{
+		"message": "instrument updated successfully",
+		"data":    updated,
+	})
+}
+
+// DeleteInstrument delists an instrument without working orders, its order and trade
+// history stays
+func (h *OrderHandler) DeleteInstrument(w http.ResponseWriter, r *http.Request) {
+	symbol := r.PathValue("symbol")
+
+	err := h.submit(symbol, func(book *orderbook.OrderBook) error {
+		if !book.Empty() {
+			return errInstrumentInUse
+		}
+		if err := h.Storage.DeleteInstrument(symbol); err != nil {
+			return err
+		}
+
+		h.mu.Lock()
+		delete(h.books, symbol)
+		h.mu.Unlock()
+		return nil
+	})
+	switch {
+	case errors.Is(err, storage.ErrInstrumentNotFound):
+		response.WriteJson(w, http.StatusNotFound, response.GeneralError(storage.ErrInstrumentNotFound))
+		return
+	case errors.Is(err, errInstrumentInUse):
+		response.WriteJson(w, http.StatusConflict, response.GeneralError(err))
+		return
+	case err != nil:
+		slog.Error("Failed to delete instrument", "symbol", symbol, "error", err)
+		response.WriteJson(w, http.StatusInternalServerError, response.GeneralErrorString("failed to delete instrument"))
+		return
+	}
+
+	slog.Info("Instrument deleted", "symbol", symbol)
+
+	response.WriteJson(w, http.StatusOK, map[string]any{
+		"message": "instrument deleted successfully",
+		"data": map[string]any{
+			"symbol": symbol,
+		},
+	})
+}
+
+func applyInstrumentUpdate(instrument *types.Instrument, req types.UpdateInstrumentRequest) error {
+	if req.BaseAsset != nil {
+		instrument.BaseAsset = *req.BaseAsset
+	}
+	if req.QuoteAsset != nil {
+		instrument.QuoteAsset = *req.QuoteAsset
+	}
+	if req.Status != nil {
+		instrument.Status = *req.Status
+	}
+	return setInstrumentSizes(instrument, req.TickSize, req.LotSize, req.MinQuantity, req.MaxQuantity)
+}
+
+// setInstrumentSizes converts the sizes of a request to units of the instrument's scales,
+// a nil size is left as it is. A max_quantity of 0 removes the limit.
+func setInstrumentSizes(instrument *types.Instrument, tickSize, lotSize, minQuantity, maxQuantity *decimal.Decimal) error {
+	sizes := []struct {
+		name  string
+		size  *decimal.Decimal
+		scale int
+		units *int64
+		zero  bool
+	}{
+		{"tick_size", tickSize, instrument.PriceScale, &instrument.TickSize, false},
+		{"lot_size", lotSize, instrument.QuantityScale, &instrument.LotSize, false},
+		{"min_quantity", minQuantity, instrument.QuantityScale, &instrument.MinQuantity, false},
+		{"max_quantity", maxQuantity, instrument.QuantityScale, &instrument.MaxQuantity, true},
+	}
+	for _, s := range sizes {
+		if s.size == nil {
+			continue
+		}
+		if s.size.Sign() < 0 || s.size.Sign() == 0 && !s.zero {
+			return fmt.Errorf("%w: %s must be greater than 0", errInvalidInstrument, s.name)
+		}
+
+		units, err := s.size.Units(s.scale)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %w", errInvalidInstrument, s.name, err)
+		}
+		*s.units = units
+	}
+	return nil
+}
+
+// validateInstrument checks that the order size limits are whole lots
+func validateInstrument(instrument types.Instrument) error {
+	quantity := func(units int64) decimal.Decimal {
+		return decimal.New(units, instrument.QuantityScale)
+	}
+
+	if instrument.MinQuantity%instrument.LotSize != 0 {
+		return fmt.Errorf("%w: min_quantity %s is not a multiple of the lot size %s", errInvalidInstrument, quantity(instrument.MinQuantity), quantity(instrument.LotSize))
+	}
+	if instrument.MaxQuantity == 0 {
+		return nil
+	}
+	if instrument.MaxQuantity%instrument.LotSize != 0 {
+		return fmt.Errorf("%w: max_quantity %s is not a multiple of the lot size %s", errInvalidInstrument, quantity(instrument.MaxQuantity), quantity(instrument.LotSize))
+	}
+	if instrument.MaxQuantity < instrument.MinQuantity {
+		return fmt.Errorf("%w: max_quantity %s is below min_quantity %s", errInvalidInstrument, quantity(instrument.MaxQuantity), quantity(instrument.MinQuantity))
+	}
+	return nil
+}
+
+// tradingInstrument returns the instrument of a symbol taking new orders
+func (h *OrderHandler) tradingInstrument(symbol string) (*types.Instrument, error) {
+	instrument, err := h.Storage.GetInstrument(symbol)
+	if errors.Is(err, storage.ErrInstrumentNotFound) {
+		return nil, fmt.Errorf("%w %q", errUnknownSymbol, symbol)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instrument %s: %w", symbol, err)
+	}
+
+	if instrument.Status != types.ACTIVE {
+		return nil, fmt.Errorf("%w: %s", errSymbolInactive, symbol)
+	}
+
+	return instrument, nil
+}
+
+// priceUnits converts a price of a request to units of the instrument's price scale, nil
+// stays nil. The price has to be a positive multiple of the tick size.
+func priceUnits(instrument *types.Instrument, name string, price *decimal.Decimal) (*int64, error) {
+	if price == nil {
+		return nil, nil
+	}
+	if price.Sign() <= 0 {
+		return nil, fmt.Errorf("%s must be greater than 0", name)
+	}
+
+	units, err := tickUnits(instrument, name, *price)
+	if err != nil {
+		return nil, err
+	}
+	return &units, nil
+}
+
+// tickUnits converts a price or a price distance to units of the instrument's price scale
+func tickUnits(instrument *types.Instrument, name string, price decimal.Decimal) (int64, error) {
+	units, err := price.Units(instrument.PriceScale)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	if units%instrument.TickSize != 0 {
+		return 0, fmt.Errorf("%s %s is not a multiple of the tick size %s", name, price, decimal.New(instrument.TickSize, instrument.PriceScale))
+	}
+	return units, nil
+}
+
+// lotUnits converts a quantity of a request to units of the instrument's quantity scale,
+// the quantity has to be a positive multiple of the lot size
+func lotUnits(instrument *types.Instrument, name string, quantity decimal.Decimal) (int64, error) {
+	if quantity.Sign() <= 0 {
+		return 0, fmt.Errorf("%s must be greater than 0", name)
+	}
+
+	units, err := quantity.Units(instrument.QuantityScale)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	if units%instrument.LotSize != 0 {
+		return 0, fmt.Errorf("%s %s is not a multiple of the lot size %s", name, quantity, decimal.New(instrument.LotSize, instrument.QuantityScale))
+	}
+	return units, nil
+}
+
+// quantityUnits converts the quantity of an order, it has to be within the instrument's
+// order size limits
+func quantityUnits(instrument *types.Instrument, quantity decimal.Decimal) (int64, error) {
+	units, err := lotUnits(instrument, "quantity", quantity)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case units < instrument.MinQuantity:
+		return 0, fmt.Errorf("quantity %s is below the minimum of %s", quantity, decimal.New(instrument.MinQuantity, instrument.QuantityScale))
+	case instrument.MaxQuantity > 0 && units > instrument.MaxQuantity:
+		return 0, fmt.Errorf("quantity %s is above the maximum of %s", quantity, decimal.New(instrument.MaxQuantity, instrument.QuantityScale))
+	}
+	return units, nil
+}
@@ -0,0 +1,35 @@
+package order
+
+import (
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/orderbook"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+)
+
+// nextLevel returns the best level of a side that isn't skipped, nil when there is none.
+// Levels are skipped once every order resting there asks for more than the incoming
+// order can trade.
+func nextLevel(book *orderbook.OrderBook, side types.OrderSide, skipped map[int64]bool) *orderbook.Level {
+	for _, level := range book.Levels(side) {
+		if !skipped[level.Price] {
+			return level
+		}
+	}
+	return nil
+}
+
+// auctionBest returns the order with the highest priority on a side that takes part in
+// an uncross. Orders with a minimum quantity sit the auction out, so do orders whose
+// quota is used up.
+func auctionBest(book *orderbook.OrderBook, side types.OrderSide, quotas map[int64]int64) *types.Order {
+	for _, level := range book.Levels(side) {
+		for _, order := range level.Orders() {
+			if quota, ok := quotas[order.OrderID]; ok && quota <= 0 {
+				continue
+			}
+			if order.MinFill() == 0 {
+				return order
+			}
+		}
+	}
+	return nil
+}
@@ -7,9 +7,13 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
-	"sort"
 	"strconv"
+	"sync"
+	"time"
 
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/config"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/orderbook"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/sequencer"
 	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/storage"
 	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
 	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/utils/response"
@@ -18,12 +22,42 @@ import (
 
 type OrderHandler struct {
 	Storage storage.Storage
+	Engine  config.Engine
+
+	sequencer *sequencer.Sequencer
+
+	mu    sync.Mutex
+	books map[string]*orderbook.OrderBook
+	// symbols whose book is in batch matching
+	batching map[string]bool
+
+	stopSweeper chan struct{}
+	sweeperDone chan struct{}
 }
 
-func NewOrderHandler(storage storage.Storage) *OrderHandler {
-	return &OrderHandler{
-		Storage: storage,
+func NewOrderHandler(storage storage.Storage, engine config.Engine) *OrderHandler {
+	h := &OrderHandler{
+		Storage:     storage,
+		Engine:      engine,
+		sequencer:   sequencer.New(sequencerQueueSize),
+		books:       make(map[string]*orderbook.OrderBook),
+		batching:    make(map[string]bool),
+		stopSweeper: make(chan struct{}),
+		sweeperDone: make(chan struct{}),
 	}
+
+	go h.sweepExpiredOrders()
+
+	return h
+}
+
+// Close stops the expiry and batch sweeper, waits for the queued order commands to finish
+// and stops accepting new ones
+func (h *OrderHandler) Close() {
+	close(h.stopSweeper)
+	<-h.sweeperDone
+
+	h.sequencer.Close()
 }
 
 func (h *OrderHandler) PlaceOrder(w http.ResponseWriter, r *http.Request) {
@@ -44,69 +78,307 @@ func (h *OrderHandler) PlaceOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tx, err := h.Storage.Begin()
-	if err != nil {
-		slog.Error("Failed to begin transaction", "error", err)
-		response.WriteJson(w, http.StatusInternalServerError, response.GeneralErrorString("failed to start transaction"))
+	now := time.Now()
+	if err := h.validateOrder(&orderBody, now); err != nil {
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
 		return
 	}
 
-	// defer rollback in case of error
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-			panic(r) // re-throw panic after rollback
-		} else if err != nil {
-			tx.Rollback()
-		}
-	}()
-
-	// create new order
-	order := &types.Order{
-		Symbol:    orderBody.Symbol,
-		Side:      orderBody.Side,
-		OrderType: orderBody.Type,
-		Price:     orderBody.Price,
-		Quantity:  orderBody.Quantity,
-		Remaining: orderBody.Quantity,
-		Status:    types.OPEN,
+	// orders are only taken for listed symbols, a typo doesn't open a new book
+	instrument, err := h.tradingInstrument(orderBody.Symbol)
+	switch {
+	case errors.Is(err, errUnknownSymbol), errors.Is(err, errSymbolInactive):
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+		return
+	case err != nil:
+		slog.Error("Failed to get instrument", "error", err)
+		response.WriteJson(w, http.StatusInternalServerError, response.GeneralErrorString("failed to place order"))
+		return
 	}
 
-	orderID, err := h.Storage.PlaceOrder(tx, *order)
+	order, err := h.newOrder(&orderBody, instrument, now)
 	if err != nil {
-		slog.Error("Failed to place order in database", "error", err)
-		response.WriteJson(w, http.StatusInternalServerError, response.GeneralErrorString("failed to place order"))
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
 		return
 	}
-
-	order.OrderID = orderID
-	slog.Info("Processing order", "order_id", orderID)
-
-	// process order for matching
-	trades, err := h.processOrder(tx, order)
+	bracket, err := newBracket(&orderBody, instrument)
 	if err != nil {
-		slog.Error("Failed to process order", "error", err)
-		response.WriteJson(w, http.StatusInternalServerError, response.GeneralErrorString("failed to process order"))
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
 		return
 	}
 
-	// commit the transaction if everything succeeded
-	if err = tx.Commit(); err != nil {
-		slog.Error("Failed to commit transaction", "error", err)
-		response.WriteJson(w, http.StatusInternalServerError, response.GeneralErrorString("failed to complete order processing"))
+	// matching runs on the symbol's sequencer, one order at a time. The order may rest
+	// in the book afterwards, so the response is built from a copy taken on the sequencer.
+	var (
+		placed     types.Order
+		trades     []types.Trade
+		selfTrades []types.SelfTrade
+	)
+	draft := *order
+	err = h.submit(order.Symbol, func(book *orderbook.OrderBook) error {
+		return retryOnConflict(func() error {
+			// start over from the request, a conflicting attempt was rolled back
+			*order = draft
+
+			var err error
+			trades, selfTrades, err = h.placeOrder(book, order, bracket)
+			placed = *order
+			return err
+		})
+	})
+	if err != nil {
+		slog.Error("Failed to place order", "error", err)
+		response.WriteJson(w, http.StatusInternalServerError, response.GeneralErrorString("failed to place order"))
 		return
 	}
 
+	data := map[string]any{
+		"order_id": placed.OrderID,
+		"status":   placed.Status,
+		"trades":   trades,
+	}
+	if placed.Reason != "" {
+		data["reason"] = placed.Reason
+	}
+	if placed.GroupID != nil {
+		data["group_id"] = *placed.GroupID
+	}
+	if len(selfTrades) > 0 {
+		data["self_trades"] = selfTrades
+	}
+
 	response.WriteJson(w, http.StatusOK, map[string]any{
 		"message": "order placed successfully",
-		"data": map[string]any{
-			"order_id": orderID,
-			"status":   order.Status,
-			"trades":   trades,
-		},
+		"data":    data,
 	})
 }
 
+// newOrder creates an open order from a validated request, its prices and quantities
+// are converted to units of the instrument's scales
+func (h *OrderHandler) newOrder(req *types.PlaceOrderRequest, instrument *types.Instrument, now time.Time) (*types.Order, error) {
+	order := &types.Order{
+		Symbol:         req.Symbol,
+		AccountID:      req.AccountID,
+		Side:           req.Side,
+		OrderType:      req.Type,
+		PostOnly:       req.PostOnly,
+		PegType:        req.PegType,
+		Hidden:         req.Hidden,
+		Dark:           req.Dark,
+		AllOrNone:      req.AllOrNone,
+		STPMode:        req.STPMode,
+		MaxSlippageBps: req.MaxSlippageBps,
+		PriceScale:     instrument.PriceScale,
+		QuantityScale:  instrument.QuantityScale,
+		Status:         types.OPEN,
+		TimeInForce:    req.TimeInForce,
+		ExpireAt:       req.ExpireAt,
+	}
+
+	var err error
+	if order.Price, err = priceUnits(instrument, "price", req.Price); err != nil {
+		return nil, err
+	}
+	if order.StopPrice, err = priceUnits(instrument, "stop_price", req.StopPrice); err != nil {
+		return nil, err
+	}
+	if order.ProtectionPrice, err = priceUnits(instrument, "protection_price", req.ProtectionPrice); err != nil {
+		return nil, err
+	}
+	if req.TrailAmount != nil {
+		trail, err := priceUnits(instrument, "trail_amount", req.TrailAmount)
+		if err != nil {
+			return nil, err
+		}
+		order.TrailAmount = *trail
+	}
+	if req.TrailPercent != nil {
+		if order.TrailPercentBps, err = req.TrailPercent.Units(2); err != nil {
+			return nil, fmt.Errorf("trail_percent: %w", err)
+		}
+		if order.TrailPercentBps <= 0 || order.TrailPercentBps >= 10000 {
+			return nil, fmt.Errorf("trail_percent must be greater than 0 and less than 100")
+		}
+	}
+	if req.PegOffset != nil {
+		if order.PegOffset, err = tickUnits(instrument, "peg_offset", *req.PegOffset); err != nil {
+			return nil, err
+		}
+	}
+	if order.PegLimit, err = priceUnits(instrument, "peg_limit", req.PegLimit); err != nil {
+		return nil, err
+	}
+	if req.LimitOffset != nil {
+		if req.LimitOffset.Sign() < 0 {
+			return nil, fmt.Errorf("limit_offset must not be negative")
+		}
+		if order.LimitOffset, err = tickUnits(instrument, "limit_offset", *req.LimitOffset); err != nil {
+			return nil, err
+		}
+	}
+
+	// the quantity of an order spending a quote amount is set from the book when it is placed
+	if req.QuoteQuantity != nil {
+		if req.QuoteQuantity.Sign() <= 0 {
+			return nil, fmt.Errorf("quote_quantity must be greater than 0")
+		}
+		if order.QuoteQuantity, err = req.QuoteQuantity.Units(instrument.PriceScale); err != nil {
+			return nil, fmt.Errorf("quote_quantity: %w", err)
+		}
+		order.QuoteRemaining = order.QuoteQuantity
+	} else if order.Quantity, err = quantityUnits(instrument, *req.Quantity); err != nil {
+		return nil, err
+	}
+	order.Remaining = order.Quantity
+	if req.DisplayQuantity != nil {
+		if order.DisplayQuantity, err = lotUnits(instrument, "display_quantity", *req.DisplayQuantity); err != nil {
+			return nil, err
+		}
+		if order.DisplayQuantity > order.Quantity {
+			return nil, fmt.Errorf("display_quantity must not be above quantity")
+		}
+		order.VisibleRemaining = order.DisplayQuantity
+	}
+	if req.MinQuantity != nil {
+		if order.MinQuantity, err = lotUnits(instrument, "min_quantity", *req.MinQuantity); err != nil {
+			return nil, err
+		}
+		if order.MinQuantity > order.Quantity {
+			return nil, fmt.Errorf("min_quantity must not be above quantity")
+		}
+	}
+
+	if order.TimeInForce == types.DAY {
+		expireAt := h.Engine.DayEndAfter(now)
+		order.ExpireAt = &expireAt
+	}
+
+	return order, nil
+}
+
+// validateOrder applies the default time in force of the order type and the account's
+// self-trade prevention mode, and rejects combinations the engine can't honour
+func (h *OrderHandler) validateOrder(req *types.PlaceOrderRequest, now time.Time) error {
+	// stop orders become market orders once triggered
+	market := req.Type == types.MARKET || req.Type == types.STOP || req.Type == types.TRAILING_STOP
+
+	if req.Type == types.TRAILING_STOP || req.Type == types.TRAILING_STOP_LIMIT {
+		if req.TrailAmount == nil && req.TrailPercent == nil {
+			return fmt.Errorf("trailing stop orders need a trail_amount or a trail_percent")
+		}
+		if req.Price != nil || req.StopPrice != nil {
+			return fmt.Errorf("the stop price of trailing stop orders follows the trail, price and stop_price are not allowed")
+		}
+	} else if req.TrailAmount != nil || req.TrailPercent != nil || req.LimitOffset != nil {
+		return fmt.Errorf("trail_amount, trail_percent and limit_offset are only allowed on trailing stop orders")
+	}
+
+	if req.Type == types.PEGGED {
+		if req.Price != nil || req.StopPrice != nil {
+			return fmt.Errorf("the price of pegged orders follows their peg, price and stop_price are not allowed, peg_limit caps it")
+		}
+		if req.TimeInForce == types.IOC || req.TimeInForce == types.FOK {
+			return fmt.Errorf("pegged orders can't be ioc or fok")
+		}
+	} else if req.PegType != "" || req.PegOffset != nil || req.PegLimit != nil {
+		return fmt.Errorf("peg_type, peg_offset and peg_limit are only allowed on pegged orders")
+	}
+
+	if req.QuoteQuantity != nil {
+		if req.Type != types.MARKET || req.Side != types.BUY {
+			return fmt.Errorf("quote_quantity is only allowed on buy market orders")
+		}
+		if req.TimeInForce == types.FOK {
+			return fmt.Errorf("quote_quantity orders can't be fok")
+		}
+	}
+
+	if market && req.DisplayQuantity != nil {
+		return fmt.Errorf("display_quantity is only allowed on limit and stop_limit orders")
+	}
+
+	if market && req.Hidden {
+		return fmt.Errorf("hidden is only allowed on limit and stop_limit orders")
+	}
+
+	if !market && (req.MaxSlippageBps > 0 || req.ProtectionPrice != nil) {
+		return fmt.Errorf("max_slippage_bps and protection_price are only allowed on market and stop orders")
+	}
+
+	// market orders without a protection of their own get the symbol's default band
+	if market && req.ProtectionPrice == nil && req.MaxSlippageBps == 0 {
+		req.MaxSlippageBps = *h.Engine.Symbol(req.Symbol).MaxSlippageBps
+	}
+
+	if req.PostOnly {
+		if req.Type != types.LIMIT {
+			return fmt.Errorf("post_only is only allowed on limit orders")
+		}
+		if req.TimeInForce == types.IOC || req.TimeInForce == types.FOK {
+			return fmt.Errorf("post_only orders can't be ioc or fok")
+		}
+	}
+
+	if req.MinQuantity != nil || req.AllOrNone {
+		if req.Type != types.LIMIT {
+			return fmt.Errorf("min_quantity and all_or_none are only allowed on limit orders")
+		}
+		if req.DisplayQuantity != nil {
+			return fmt.Errorf("min_quantity and all_or_none orders can't be iceberg orders")
+		}
+	}
+
+	if req.Dark {
+		if req.Type != types.LIMIT {
+			return fmt.Errorf("dark is only allowed on limit orders")
+		}
+		if req.TimeInForce == types.FOK {
+			return fmt.Errorf("dark orders can't be fok")
+		}
+		if req.PostOnly || req.DisplayQuantity != nil || req.Hidden || req.MinQuantity != nil || req.AllOrNone {
+			return fmt.Errorf("dark orders can't be post_only, iceberg, hidden, min_quantity or all_or_none orders")
+		}
+		if req.TakeProfit != nil || req.StopLoss != nil {
+			return fmt.Errorf("take_profit and stop_loss are not allowed on dark orders")
+		}
+	}
+
+	if req.TimeInForce == "" {
+		req.TimeInForce = types.GTC
+		if market {
+			req.TimeInForce = types.IOC
+		}
+	}
+
+	if req.STPMode == "" {
+		req.STPMode = h.Engine.STPModeFor(req.AccountID)
+	}
+
+	if err := validateBracket(req); err != nil {
+		return err
+	}
+
+	switch req.TimeInForce {
+	case types.GTC, types.DAY, types.GTD:
+		if market {
+			return fmt.Errorf("market and stop orders only support ioc or fok time in force")
+		}
+	}
+
+	if req.TimeInForce != types.GTD {
+		if req.ExpireAt != nil {
+			return fmt.Errorf("expire_at is only allowed with gtd time in force")
+		}
+		return nil
+	}
+
+	if !req.ExpireAt.After(now) {
+		return fmt.Errorf("expire_at must be in the future")
+	}
+
+	return nil
+}
+
 func (h *OrderHandler) GetOrderStatus(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -147,22 +419,7 @@ func (h *OrderHandler) CancelOrder(w http.ResponseWriter, r *http.Request) {
 
 	slog.Info("Cancelling order", "order_id", orderID)
 
-	tx, err := h.Storage.Begin()
-	if err != nil {
-		slog.Error("Failed to begin transaction", "error", err)
-		response.WriteJson(w, http.StatusInternalServerError, response.GeneralErrorString("failed to start transaction"))
-		return
-	}
-
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-			panic(r)
-		} else if err != nil {
-			tx.Rollback()
-		}
-	}()
-
+	// the symbol is needed to route the cancel to its sequencer
 	order, err := h.Storage.GetOrderStatus(orderID)
 	if err != nil {
 		slog.Error("Failed to fetch order", "error", err)
@@ -170,33 +427,25 @@ func (h *OrderHandler) CancelOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	switch order.Status {
-	case types.FILLED:
-		response.WriteJson(w, http.StatusBadRequest, response.GeneralErrorString("cannot cancel a filled order"))
-		return
-	case types.CANCELLED:
-		response.WriteJson(w, http.StatusBadRequest, response.GeneralErrorString("order is already cancelled"))
+	err = h.submit(order.Symbol, func(book *orderbook.OrderBook) error {
+		return retryOnConflict(func() error {
+			return h.cancelOrder(book, orderID)
+		})
+	})
+	switch {
+	case errors.Is(err, errOrderNotFound):
+		response.WriteJson(w, http.StatusNotFound, response.GeneralError(err))
 		return
-	case types.OPEN, types.PARTIAL:
-		// proceed with cancellation
-	default:
-		response.WriteJson(w, http.StatusBadRequest, response.GeneralErrorString("invalid order status"))
+	case errors.Is(err, errOrderFilled), errors.Is(err, errOrderCancelled), errors.Is(err, errOrderExpired),
+		errors.Is(err, errOrderRejected), errors.Is(err, errInvalidOrderStatus):
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
 		return
-	}
-
-	err = h.Storage.MarkOrderCancelled(tx, orderID)
-	if err != nil {
+	case err != nil:
 		slog.Error("Failed to cancel order", "error", err)
 		response.WriteJson(w, http.StatusInternalServerError, response.GeneralErrorString("failed to cancel order"))
 		return
 	}
 
-	if err = tx.Commit(); err != nil {
-		slog.Error("Failed to commit transaction", "error", err)
-		response.WriteJson(w, http.StatusInternalServerError, response.GeneralErrorString("failed to complete cancellation"))
-		return
-	}
-
 	slog.Info("Order cancelled successfully", "order_id", orderID)
 
 	response.WriteJson(w, http.StatusOK, map[string]any{
@@ -208,72 +457,121 @@ func (h *OrderHandler) CancelOrder(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func (h *OrderHandler) GetOrderBook(w http.ResponseWriter, r *http.Request) {
-	symbol := r.URL.Query().Get("symbol")
-	if symbol == "" {
-		response.WriteJson(w, http.StatusBadRequest, response.GeneralErrorString("symbol is required"))
+func (h *OrderHandler) AmendOrder(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("orderId")
+	orderID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("invalid order id")))
 		return
 	}
 
-	orders, err := h.Storage.GetMatchingOrders(symbol, nil)
+	var amendBody types.AmendOrderRequest
+	err = json.NewDecoder(r.Body).Decode(&amendBody)
+	if errors.Is(err, io.EOF) {
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("empty body")))
+		return
+	}
 	if err != nil {
-		slog.Error("failed to get orders from database", "error", err)
-		response.WriteJson(w, http.StatusInternalServerError, response.GeneralErrorString("failed to get order book"))
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralErrorString("invalid request body"))
 		return
 	}
 
-	snapshot := types.OrderBookSnapshot{
-		Symbol: symbol,
-		Bids:   []types.OrderBookEntry{},
-		Asks:   []types.OrderBookEntry{},
+	if err := validator.New().Struct(amendBody); err != nil {
+		validateErrors := err.(validator.ValidationErrors)
+		response.WriteJson(w, http.StatusBadRequest, response.ValidationError(validateErrors))
+		return
 	}
 
-	bidLevels := make(map[int64]int64)
-	askLevels := make(map[int64]int64)
-
-	for _, order := range orders {
-		if order.Symbol != symbol || order.Status == types.CANCELLED {
-			continue
-		}
+	slog.Info("Amending order", "order_id", orderID)
 
-		if order.Price == nil {
-			// skip market orders as they don't have a price
-			continue
-		}
+	// the symbol is needed to route the amendment to its sequencer
+	order, err := h.Storage.GetOrderStatus(orderID)
+	if err != nil {
+		slog.Error("Failed to fetch order", "error", err)
+		response.WriteJson(w, http.StatusNotFound, response.GeneralErrorString("order not found"))
+		return
+	}
 
-		switch order.Side {
-		case types.BUY:
-			bidLevels[*order.Price] += order.Remaining
-		case types.SELL:
-			askLevels[*order.Price] += order.Remaining
-		}
+	instrument, err := h.Storage.GetInstrument(order.Symbol)
+	if err != nil {
+		slog.Error("Failed to get instrument", "error", err)
+		response.WriteJson(w, http.StatusInternalServerError, response.GeneralErrorString("failed to amend order"))
+		return
 	}
 
-	// convert bid levels to order book entries
-	for price, quantity := range bidLevels {
-		snapshot.Bids = append(snapshot.Bids, types.OrderBookEntry{
-			Price:    price,
-			Quantity: quantity,
-		})
+	// the new price and quantity are checked like those of a new order
+	price, err := priceUnits(instrument, "price", amendBody.Price)
+	if err != nil {
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+		return
+	}
+	var quantity *int64
+	if amendBody.Quantity != nil {
+		units, err := quantityUnits(instrument, *amendBody.Quantity)
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+			return
+		}
+		quantity = &units
 	}
 
-	// convert ask levels to order book entries
-	for price, quantity := range askLevels {
-		snapshot.Asks = append(snapshot.Asks, types.OrderBookEntry{
-			Price:    price,
-			Quantity: quantity,
+	var (
+		amended types.Order
+		trades  []types.Trade
+	)
+	err = h.submit(order.Symbol, func(book *orderbook.OrderBook) error {
+		return retryOnConflict(func() error {
+			var err error
+			amended, trades, err = h.amendOrder(book, orderID, price, quantity)
+			return err
 		})
+	})
+	switch {
+	case errors.Is(err, errOrderNotFound):
+		response.WriteJson(w, http.StatusNotFound, response.GeneralError(err))
+		return
+	case errors.Is(err, errOrderNotAmendable), errors.Is(err, errPriceNotAmendable), errors.Is(err, errQuantityBelowFilled),
+		errors.Is(err, errNothingToAmend), errors.Is(err, errPostOnlyWouldTrade), errors.Is(err, errSymbolHalted),
+		errors.Is(err, errPriceOutsideCollar), errors.Is(err, errPegNotAmendable),
+		errors.Is(err, errDarkNotAmendable):
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+		return
+	case err != nil:
+		slog.Error("Failed to amend order", "error", err)
+		response.WriteJson(w, http.StatusInternalServerError, response.GeneralErrorString("failed to amend order"))
+		return
 	}
 
-	// sort bids, highest bid first
-	sort.Slice(snapshot.Bids, func(i, j int) bool {
-		return snapshot.Bids[i].Price > snapshot.Bids[j].Price
+	response.WriteJson(w, http.StatusOK, map[string]any{
+		"message": "order amended successfully",
+		"data": map[string]any{
+			"order":  amended,
+			"trades": trades,
+		},
 	})
+}
 
-	// sort asks, lowest ask first
-	sort.Slice(snapshot.Asks, func(i, j int) bool {
-		return snapshot.Asks[i].Price < snapshot.Asks[j].Price
+func (h *OrderHandler) GetOrderBook(w http.ResponseWriter, r *http.Request) {
+	symbol := r.URL.Query().Get("symbol")
+	if symbol == "" {
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralErrorString("symbol is required"))
+		return
+	}
+
+	var snapshot types.OrderBookSnapshot
+	err := h.submit(symbol, func(book *orderbook.OrderBook) error {
+		snapshot = book.Snapshot()
+		return nil
 	})
+	if errors.Is(err, storage.ErrInstrumentNotFound) {
+		response.WriteJson(w, http.StatusNotFound, response.GeneralError(storage.ErrInstrumentNotFound))
+		return
+	}
+	if err != nil {
+		slog.Error("failed to load order book", "error", err)
+		response.WriteJson(w, http.StatusInternalServerError, response.GeneralErrorString("failed to get order book"))
+		return
+	}
 
 	response.WriteJson(w, http.StatusOK, map[string]any{
 		"message": "order book retrieved successfully",
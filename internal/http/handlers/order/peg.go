@@ -0,0 +1,149 @@
+package order
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/orderbook"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/storage"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+)
+
+// startPeg sets the first price of a pegged order from the top of the book, a pegged
+// order with nothing to follow is rejected
+func (h *OrderHandler) startPeg(book *orderbook.OrderBook, order *types.Order) {
+	if !order.IsPegged() || order.Status == types.REJECTED {
+		return
+	}
+
+	price, ok := pegPrice(book, order)
+	if !ok {
+		order.Status = types.REJECTED
+		order.Reason = fmt.Sprintf("pegged order has no %s price to follow", order.PegType)
+		return
+	}
+	order.Price = &price
+}
+
+// repricePegs moves the resting pegged orders to where their peg is now and returns the
+// trades and prevented self trades of the ones that cross the book there. It reports
+// whether any of them traded. Pegs only move in continuous trading, a pegged order whose
+// reference is gone or whose new price is outside the price collars keeps its price.
+func (h *OrderHandler) repricePegs(tx storage.Tx, book *orderbook.OrderBook) ([]types.Trade, []types.SelfTrade, bool, error) {
+	var (
+		trades     []types.Trade
+		selfTrades []types.SelfTrade
+	)
+
+	if book.Phase != types.CONTINUOUS {
+		return nil, nil, false, nil
+	}
+
+	for _, order := range book.Pegged() {
+		// an earlier peg may have traded this one out of the book
+		if _, ok := book.Get(order.OrderID); !ok {
+			continue
+		}
+
+		price, ok := pegPrice(book, order)
+		if !ok || price == *order.Price {
+			continue
+		}
+
+		// a peg doesn't follow its reference outside the price collars, it keeps its price
+		probe := *order
+		probe.Price = &price
+		if err := h.checkPriceCollars(book, &probe, time.Now()); err != nil {
+			return nil, nil, false, err
+		}
+		if probe.Status == types.REJECTED {
+			continue
+		}
+
+		fresh, err := h.lockRestingOrder(tx, book, order)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		if !fresh {
+			continue
+		}
+
+		// a new price goes to the back of its level like an amendment
+		book.Remove(order.OrderID)
+		order.Price = &price
+		if err := h.Storage.AmendOrder(tx, *order, true); err != nil {
+			return nil, nil, false, fmt.Errorf("failed to reprice pegged order %d: %w", order.OrderID, err)
+		}
+
+		slog.Info("Pegged order repriced", "order_id", order.OrderID, "peg_type", order.PegType, "price", price)
+
+		orderTrades, orderSelfTrades, err := h.processOrder(tx, book, order)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		trades = append(trades, orderTrades...)
+		selfTrades = append(selfTrades, orderSelfTrades...)
+	}
+
+	return trades, selfTrades, len(trades) > 0, nil
+}
+
+// pegPrice returns where the peg of an order is now, its offset added and capped at its
+// limit. A midpoint between ticks is rounded away from the opposite side. It is false
+// when a price the peg follows is missing.
+func pegPrice(book *orderbook.OrderBook, order *types.Order) (int64, bool) {
+	var (
+		reference int64
+		ok        bool
+	)
+
+	switch order.PegType {
+	case types.PEG_PRIMARY:
+		reference, ok = referencePrice(book, order.Side)
+	case types.PEG_MARKET:
+		reference, ok = referencePrice(book, order.Side.Opposite())
+	case types.PEG_MIDPOINT:
+		bid, hasBid := referencePrice(book, types.BUY)
+		ask, hasAsk := referencePrice(book, types.SELL)
+		if !hasBid || !hasAsk {
+			return 0, false
+		}
+
+		ticks := (bid + ask) / book.TickSize
+		if order.Side == types.SELL {
+			ticks++
+		}
+		reference, ok = ticks/2*book.TickSize, true
+	}
+	if !ok {
+		return 0, false
+	}
+
+	price := reference + order.PegOffset
+	if order.PegLimit != nil {
+		if order.Side == types.BUY {
+			price = min(price, *order.PegLimit)
+		} else {
+			price = max(price, *order.PegLimit)
+		}
+	}
+	if price <= 0 {
+		return 0, false
+	}
+
+	return price, true
+}
+
+// referencePrice returns the best price of a side that pegs follow, set by the displayed
+// orders that aren't pegged themselves
+func referencePrice(book *orderbook.OrderBook, side types.OrderSide) (int64, bool) {
+	for _, level := range book.Levels(side) {
+		for _, order := range level.Orders() {
+			if !order.IsPegged() && !order.Hidden {
+				return level.Price, true
+			}
+		}
+	}
+	return 0, false
+}
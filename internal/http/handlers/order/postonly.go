@@ -0,0 +1,56 @@
+package order
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/config"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/decimal"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/orderbook"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+)
+
+// checkPostOnly keeps a post only order from trading on arrival. A crossing order is
+// rejected, or in reprice mode moved one tick behind the opposite best price. A best
+// price of hidden orders only isn't given away, the order is rejected without it.
+func (h *OrderHandler) checkPostOnly(book *orderbook.OrderBook, order *types.Order) {
+	if !order.PostOnly || order.Price == nil || order.Status == types.REJECTED {
+		return
+	}
+
+	best := book.BestLevel(order.Side.Opposite())
+	if best == nil {
+		return
+	}
+
+	crosses := *order.Price >= best.Price
+	if order.Side == types.SELL {
+		crosses = *order.Price <= best.Price
+	}
+	if !crosses {
+		return
+	}
+
+	// any price behind a hidden level would tell where it is, and a price behind a
+	// displayed level further out would still trade against it
+	if h.Engine.PostOnly == config.PostOnlyReprice && best.Quantity > 0 {
+		price := best.Price - book.TickSize
+		if order.Side == types.SELL {
+			price = best.Price + book.TickSize
+		}
+
+		if price > 0 {
+			slog.Info("Post only order repriced", "symbol", order.Symbol, "side", order.Side, "price", *order.Price, "new_price", price)
+			order.Price = &price
+			return
+		}
+	}
+
+	order.Status = types.REJECTED
+	// a level of hidden orders only doesn't give its price away
+	if best.Quantity == 0 {
+		order.Reason = fmt.Sprintf("post only order would trade against the %s side", order.Side.Opposite())
+		return
+	}
+	order.Reason = fmt.Sprintf("post only order would trade against the %s side at %s", order.Side.Opposite(), decimal.New(best.Price, book.PriceScale))
+}
@@ -0,0 +1,37 @@
+package order
+
+import (
+	"log/slog"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/decimal"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/orderbook"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+)
+
+// setProtectionPrice turns the slippage band of a market order into a protection price
+// around the opposite best price when the order starts working. Stop orders get theirs
+// when triggered, from the touch at that time.
+func (h *OrderHandler) setProtectionPrice(book *orderbook.OrderBook, order *types.Order) {
+	if !order.IsMarket() || order.ProtectionPrice != nil || order.MaxSlippageBps <= 0 {
+		return
+	}
+
+	touch := book.BestLevel(order.Side.Opposite())
+	if touch == nil {
+		return
+	}
+
+	// below 10000 bps the slippage is smaller than the touch price, it always fits
+	slippage, _ := decimal.MulDiv(touch.Price, order.MaxSlippageBps, 10000)
+	price := touch.Price + slippage
+	if order.Side == types.SELL {
+		price = touch.Price - slippage
+	}
+	order.ProtectionPrice = &price
+
+	slog.Info("Market order protected",
+		"order_id", order.OrderID,
+		"touch", touch.Price,
+		"max_slippage_bps", order.MaxSlippageBps,
+		"protection_price", price)
+}
@@ -0,0 +1,97 @@
+package order
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/decimal"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/orderbook"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/storage"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+)
+
+// sizeQuoteOrder gives a buy order spending a quote amount the quantity that amount buys
+// at the best ask. Asks behind it are dearer, so the order never needs more. An amount
+// buying a quantity outside the instrument's order size limits is rejected.
+func (h *OrderHandler) sizeQuoteOrder(book *orderbook.OrderBook, order *types.Order) {
+	if !order.HasQuoteQuantity() || order.Status == types.REJECTED {
+		return
+	}
+
+	best := book.BestLevel(types.SELL)
+	if best == nil {
+		order.Status = types.REJECTED
+		order.Reason = "no asks to spend the quote_quantity on"
+		return
+	}
+
+	quantity, ok := affordableQuantity(book, order, best.Price)
+	if !ok {
+		order.Status = types.REJECTED
+		order.Reason = fmt.Sprintf("quote_quantity %s buys more than an order can hold at the best ask %s",
+			decimal.New(order.QuoteQuantity, book.PriceScale), decimal.New(best.Price, book.PriceScale))
+		return
+	}
+
+	order.Quantity = quantity
+	order.Remaining = order.Quantity
+	switch {
+	case order.Quantity == 0:
+		order.Status = types.REJECTED
+		order.Reason = fmt.Sprintf("quote_quantity %s doesn't buy a lot at the best ask %s",
+			decimal.New(order.QuoteQuantity, book.PriceScale), decimal.New(best.Price, book.PriceScale))
+	case order.Quantity < book.MinQuantity:
+		order.Status = types.REJECTED
+		order.Reason = fmt.Sprintf("quote_quantity %s buys %s at the best ask %s, below the minimum of %s",
+			decimal.New(order.QuoteQuantity, book.PriceScale), decimal.New(order.Quantity, book.QuantityScale),
+			decimal.New(best.Price, book.PriceScale), decimal.New(book.MinQuantity, book.QuantityScale))
+	case book.MaxQuantity > 0 && order.Quantity > book.MaxQuantity:
+		order.Status = types.REJECTED
+		order.Reason = fmt.Sprintf("quote_quantity %s buys %s at the best ask %s, above the maximum of %s",
+			decimal.New(order.QuoteQuantity, book.PriceScale), decimal.New(order.Quantity, book.QuantityScale),
+			decimal.New(best.Price, book.PriceScale), decimal.New(book.MaxQuantity, book.QuantityScale))
+	}
+}
+
+// affordableQuantity returns the whole lots the order's unspent quote amount buys at price,
+// false when that is more than an int64 of units holds
+func affordableQuantity(book *orderbook.OrderBook, order *types.Order, price int64) (int64, bool) {
+	quantity, ok := decimal.MulDiv(order.QuoteRemaining, decimal.Pow10(book.QuantityScale), price)
+	if !ok {
+		return 0, false
+	}
+	return quantity - quantity%book.LotSize, true
+}
+
+// spendQuote takes the notional of a trade off the order's quote amount, rounded down to
+// the price scale
+func spendQuote(order *types.Order, price, quantity int64) {
+	if !order.HasQuoteQuantity() {
+		return
+	}
+	// the trade was sized from the quote amount, its notional never exceeds what is left
+	spent, ok := decimal.MulDiv(price, quantity, decimal.Pow10(order.QuantityScale))
+	if !ok || spent > order.QuoteRemaining {
+		spent = order.QuoteRemaining
+	}
+	order.QuoteRemaining -= spent
+}
+
+// finishQuoteOrder ends an order whose quote amount can't buy another lot, its quantity
+// shrinks to what it bought and it is filled
+func (h *OrderHandler) finishQuoteOrder(tx storage.Tx, order *types.Order) error {
+	order.Quantity -= order.Remaining
+	order.Remaining = 0
+	h.updateOrderStatus(order)
+
+	if err := h.Storage.AmendOrder(tx, *order, false); err != nil {
+		return fmt.Errorf("failed to finish order %d: %w", order.OrderID, err)
+	}
+
+	slog.Info("Quote quantity spent",
+		"order_id", order.OrderID,
+		"quantity", order.Quantity,
+		"quote_remaining", order.QuoteRemaining)
+
+	return nil
+}
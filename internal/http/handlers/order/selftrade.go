@@ -0,0 +1,92 @@
+package order
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/orderbook"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/storage"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+)
+
+// preventSelfTrade applies the new order's self-trade prevention mode when it meets a
+// resting order of its own account, instead of trading the two. The new order may rest
+// in the book as well, like the newer order of an auction pair, it keeps its place there
+// when it is decremented.
+func (h *OrderHandler) preventSelfTrade(tx storage.Tx, book *orderbook.OrderBook, newOrder, resting *types.Order) (types.SelfTrade, error) {
+	selfTrade := types.SelfTrade{
+		OrderID:        newOrder.OrderID,
+		RestingOrderID: resting.OrderID,
+		Mode:           newOrder.STPMode,
+		QuantityScale:  newOrder.QuantityScale,
+	}
+
+	var cancelNew, cancelResting bool
+	switch newOrder.STPMode {
+	case types.CANCEL_OLDEST:
+		cancelResting = true
+	case types.CANCEL_BOTH:
+		cancelNew, cancelResting = true, true
+	case types.DECREMENT_CANCEL:
+		// the smaller order is cancelled, the larger one shrinks by its size
+		quantity := min(newOrder.Remaining, resting.Remaining)
+		selfTrade.Quantity = quantity
+
+		cancelNew = newOrder.Remaining == quantity
+		cancelResting = resting.Remaining == quantity
+
+		if !cancelNew {
+			if _, ok := book.Get(newOrder.OrderID); ok {
+				book.Reduce(newOrder, quantity)
+			} else {
+				newOrder.Remaining -= quantity
+			}
+			newOrder.Quantity -= quantity
+			if err := h.Storage.AmendOrder(tx, *newOrder, false); err != nil {
+				return selfTrade, fmt.Errorf("failed to decrement order %d: %w", newOrder.OrderID, err)
+			}
+		}
+		if !cancelResting {
+			book.Reduce(resting, quantity)
+			resting.Quantity -= quantity
+			if err := h.Storage.AmendOrder(tx, *resting, false); err != nil {
+				return selfTrade, fmt.Errorf("failed to decrement order %d: %w", resting.OrderID, err)
+			}
+		}
+	default:
+		cancelNew = true
+	}
+
+	if cancelResting {
+		book.Remove(resting.OrderID)
+		if err := h.cancelSelfTrade(tx, resting, newOrder.OrderID); err != nil {
+			return selfTrade, err
+		}
+	}
+	if cancelNew {
+		book.Remove(newOrder.OrderID)
+		if err := h.cancelSelfTrade(tx, newOrder, resting.OrderID); err != nil {
+			return selfTrade, err
+		}
+	}
+
+	slog.Info("Self trade prevented",
+		"account_id", newOrder.AccountID,
+		"order_id", newOrder.OrderID,
+		"resting_order_id", resting.OrderID,
+		"mode", newOrder.STPMode,
+		"quantity", selfTrade.Quantity)
+
+	return selfTrade, nil
+}
+
+// cancelSelfTrade cancels an order that would have traded against other, an order of its own account
+func (h *OrderHandler) cancelSelfTrade(tx storage.Tx, order *types.Order, other int64) error {
+	order.Status = types.CANCELLED
+	order.Reason = fmt.Sprintf("self trade prevention against order %d", other)
+
+	if err := h.Storage.MarkOrderCancelled(tx, order.OrderID, order.Reason); err != nil {
+		return fmt.Errorf("failed to cancel order %d: %w", order.OrderID, err)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package order
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/orderbook"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/storage"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+)
+
+// triggerStops works every stop order crossed by the last trade price, the bracket legs
+// opened by fills and the pegged orders the top of the book moved, and returns their
+// trades and prevented self trades. Those trades move the price as well, so it repeats
+// until no stop is crossed. The dark book then matches at the midpoint left behind.
+func (h *OrderHandler) triggerStops(tx storage.Tx, book *orderbook.OrderBook) ([]types.Trade, []types.SelfTrade, error) {
+	var (
+		trades     []types.Trade
+		selfTrades []types.SelfTrade
+	)
+
+	for {
+		// store where the trades moved the trailing stops
+		for _, order := range book.TrailedStops() {
+			if err := h.Storage.UpdateStopPrice(tx, *order); err != nil {
+				return nil, nil, fmt.Errorf("failed to trail stop order %d: %w", order.OrderID, err)
+			}
+		}
+
+		activatedTrades, activatedSelfTrades, activated, err := h.workActivated(tx, book)
+		if err != nil {
+			return nil, nil, err
+		}
+		trades = append(trades, activatedTrades...)
+		selfTrades = append(selfTrades, activatedSelfTrades...)
+
+		repricedTrades, repricedSelfTrades, repriced, err := h.repricePegs(tx, book)
+		if err != nil {
+			return nil, nil, err
+		}
+		trades = append(trades, repricedTrades...)
+		selfTrades = append(selfTrades, repricedSelfTrades...)
+
+		lastPrice, ok := book.LastPrice()
+		if !ok {
+			break
+		}
+
+		// nothing trades while the symbol is halted, closed or in an auction call, stops
+		// crossed by the last trades keep waiting and trigger once it trades again
+		var triggered []*types.Order
+		switch book.Phase {
+		case types.HALTED, types.CLOSED, types.AUCTION:
+		default:
+			triggered = book.TriggerStops(lastPrice)
+		}
+		if len(triggered) == 0 {
+			if activated || repriced {
+				// the activated and repriced orders may have moved the trailing stops, opened
+				// more legs or moved the top of the book
+				continue
+			}
+			break
+		}
+
+		for _, order := range triggered {
+			// another instance may have triggered or cancelled it already
+			stored, err := h.Storage.GetOrderStatusTx(tx, order.OrderID)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to lock stop order %d: %w", order.OrderID, err)
+			}
+			if stored.Status != types.OPEN {
+				continue
+			}
+
+			order.Status = types.TRIGGERED
+			if order.OrderType == types.TRAILING_STOP_LIMIT {
+				// the limit price is only known once the trail stops moving
+				price := *order.StopPrice - order.LimitOffset
+				if order.Side == types.BUY {
+					price = *order.StopPrice + order.LimitOffset
+				}
+				order.Price = &price
+			}
+
+			// the limit price reaches the book now, it has to be within the collars
+			cancelled, err := h.cancelOutsideCollars(tx, book, order)
+			if err != nil {
+				return nil, nil, err
+			}
+			if cancelled {
+				continue
+			}
+
+			if order.OrderType == types.TRAILING_STOP_LIMIT {
+				err = h.Storage.AmendOrder(tx, *order, false)
+			} else {
+				err = h.Storage.UpdateOrder(tx, *order)
+			}
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to trigger stop order %d: %w", order.OrderID, err)
+			}
+
+			slog.Info("Stop order triggered",
+				"order_id", order.OrderID,
+				"symbol", order.Symbol,
+				"stop_price", *order.StopPrice,
+				"last_price", lastPrice)
+
+			orderTrades, orderSelfTrades, err := h.processOrder(tx, book, order)
+			if err != nil {
+				return nil, nil, err
+			}
+			trades = append(trades, orderTrades...)
+			selfTrades = append(selfTrades, orderSelfTrades...)
+		}
+	}
+
+	// the lit book has settled, cross the dark book at its midpoint
+	darkTrades, darkSelfTrades, err := h.matchDark(tx, book)
+	if err != nil {
+		return nil, nil, err
+	}
+	trades = append(trades, darkTrades...)
+	selfTrades = append(selfTrades, darkSelfTrades...)
+
+	return trades, selfTrades, nil
+}
+
+// startTrailing sets the first stop price of a trailing stop from the last trade price,
+// or from the opposite best price before the symbol's first trade. A trailing stop with
+// nothing to trail is rejected.
+func (h *OrderHandler) startTrailing(book *orderbook.OrderBook, order *types.Order) {
+	if !order.IsTrailing() || order.Status == types.REJECTED {
+		return
+	}
+
+	reference, ok := book.LastPrice()
+	if !ok {
+		best := book.BestLevel(order.Side.Opposite())
+		if best == nil {
+			order.Status = types.REJECTED
+			order.Reason = "trailing stop order has no last trade or resting order to trail"
+			return
+		}
+		reference = best.Price
+	}
+
+	order.Trail(reference)
+}
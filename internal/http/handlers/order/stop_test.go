@@ -0,0 +1,30 @@
+package order
+
+import (
+	"testing"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/orderbook"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+)
+
+func TestTriggerStopsOutsideTrading(t *testing.T) {
+	for _, phase := range []types.TradingPhase{types.HALTED, types.CLOSED, types.AUCTION} {
+		t.Run(string(phase), func(t *testing.T) {
+			h := &OrderHandler{}
+			book := orderbook.New("TEST")
+			book.Phase = phase
+
+			// the trade that tripped the breaker crossed the stop
+			stopPrice := int64(99)
+			book.AddStop(&types.Order{OrderID: 1, Side: types.SELL, OrderType: types.STOP, StopPrice: &stopPrice, Quantity: 5, Remaining: 5, Status: types.OPEN})
+			book.SetLastPrice(98)
+
+			if _, _, err := h.triggerStops(nil, book); err != nil {
+				t.Fatalf("triggerStops() error = %v", err)
+			}
+			if _, ok := book.GetStop(1); !ok {
+				t.Errorf("stop order left the trigger book while the symbol is %s", phase)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/utils/response"
+)
+
+// AdminOnly lets a request through when it carries the admin token as its bearer token.
+// Without a configured token every request is refused.
+func AdminOnly(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			response.WriteJson(w, http.StatusUnauthorized, response.GeneralErrorString("admin token required"))
+			return
+		}
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,117 @@
+package matching
+
+import (
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/decimal"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+)
+
+// Allocation is the quantity an incoming order trades against one resting order
+type Allocation struct {
+	Order    *types.Order
+	Quantity int64
+}
+
+// Algorithm decides how an incoming quantity is shared among the orders resting at
+// the best price level. Orders are given oldest first and only their tradable
+// quantity can be allocated, for an iceberg that is its shown slice. The allocations
+// add up to the incoming quantity, or to the whole level when it is smaller.
+type Algorithm interface {
+	Allocate(orders []*types.Order, quantity int64) []Allocation
+}
+
+// FIFO fills the oldest order first (price-time priority)
+type FIFO struct{}
+
+func (FIFO) Allocate(orders []*types.Order, quantity int64) []Allocation {
+	allocated := make([]int64, len(orders))
+	fillInTimeOrder(orders, allocated, quantity)
+	return allocations(orders, allocated)
+}
+
+// ProRata shares the quantity in proportion to the size of each resting order.
+// Shares are rounded down to whole lots of LotSize units and shares below
+// MinAllocation lots are dropped, whatever is left over after rounding goes to the
+// orders in time priority. A zero LotSize counts single units.
+type ProRata struct {
+	MinAllocation int64
+	LotSize       int64
+}
+
+func (p ProRata) Allocate(orders []*types.Order, quantity int64) []Allocation {
+	allocated := make([]int64, len(orders))
+	p.share(orders, allocated, quantity)
+	return allocations(orders, allocated)
+}
+
+// share allocates quantity pro-rata on top of what was already allocated
+func (p ProRata) share(orders []*types.Order, allocated []int64, quantity int64) {
+	var total int64
+	for i, order := range orders {
+		total += order.Tradable() - allocated[i]
+	}
+	if total <= 0 || quantity <= 0 {
+		return
+	}
+
+	// the whole level trades, nothing to share
+	if quantity >= total {
+		fillInTimeOrder(orders, allocated, quantity)
+		return
+	}
+
+	lot := max(p.LotSize, 1)
+	left := quantity
+	for i, order := range orders {
+		share, ok := decimal.MulDiv(quantity, order.Tradable()-allocated[i], total)
+		share -= share % lot
+		if !ok || share <= 0 || share < p.MinAllocation*lot {
+			continue
+		}
+		allocated[i] += share
+		left -= share
+	}
+
+	fillInTimeOrder(orders, allocated, left)
+}
+
+// Hybrid fills the oldest order at the level first (top order priority) and shares
+// the rest of the quantity pro-rata among the orders behind it
+type Hybrid struct {
+	MinAllocation int64
+	LotSize       int64
+}
+
+func (h Hybrid) Allocate(orders []*types.Order, quantity int64) []Allocation {
+	allocated := make([]int64, len(orders))
+	if len(orders) > 0 {
+		allocated[0] = min(quantity, orders[0].Tradable())
+		ProRata(h).share(orders, allocated, quantity-allocated[0])
+	}
+	return allocations(orders, allocated)
+}
+
+// fillInTimeOrder allocates quantity to the oldest orders first, on top of what was
+// already allocated to them
+func fillInTimeOrder(orders []*types.Order, allocated []int64, quantity int64) {
+	for i, order := range orders {
+		if quantity <= 0 {
+			return
+		}
+		fill := min(quantity, order.Tradable()-allocated[i])
+		if fill <= 0 {
+			continue
+		}
+		allocated[i] += fill
+		quantity -= fill
+	}
+}
+
+func allocations(orders []*types.Order, allocated []int64) []Allocation {
+	var result []Allocation
+	for i, order := range orders {
+		if allocated[i] > 0 {
+			result = append(result, Allocation{Order: order, Quantity: allocated[i]})
+		}
+	}
+	return result
+}
@@ -0,0 +1,140 @@
+package matching
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+)
+
+// restingOrders returns orders of the given remaining sizes, oldest first, with ids from 1
+func restingOrders(sizes ...int64) []*types.Order {
+	orders := make([]*types.Order, len(sizes))
+	for i, size := range sizes {
+		orders[i] = &types.Order{OrderID: int64(i + 1), Remaining: size}
+	}
+	return orders
+}
+
+// quantities returns the allocated quantity per order id
+func quantities(allocations []Allocation) map[int64]int64 {
+	result := make(map[int64]int64)
+	for _, allocation := range allocations {
+		result[allocation.Order.OrderID] = allocation.Quantity
+	}
+	return result
+}
+
+func TestAllocate(t *testing.T) {
+	iceberg := restingOrders(10, 5)
+	iceberg[0].DisplayQuantity, iceberg[0].VisibleRemaining = 2, 2
+
+	tests := []struct {
+		name      string
+		algorithm Algorithm
+		orders    []*types.Order
+		quantity  int64
+		want      map[int64]int64
+	}{
+		{
+			name:      "fifo fills the oldest order first",
+			algorithm: FIFO{},
+			orders:    restingOrders(5, 3, 4),
+			quantity:  7,
+			want:      map[int64]int64{1: 5, 2: 2},
+		},
+		{
+			name:      "fifo takes the whole level",
+			algorithm: FIFO{},
+			orders:    restingOrders(5, 3),
+			quantity:  10,
+			want:      map[int64]int64{1: 5, 2: 3},
+		},
+		{
+			name:      "fifo only trades the shown slice of an iceberg",
+			algorithm: FIFO{},
+			orders:    iceberg,
+			quantity:  4,
+			want:      map[int64]int64{1: 2, 2: 2},
+		},
+		{
+			name:      "pro rata shares in proportion, the remainder goes in time priority",
+			algorithm: ProRata{MinAllocation: 1},
+			orders:    restingOrders(6, 3, 1),
+			quantity:  5,
+			want:      map[int64]int64{1: 4, 2: 1},
+		},
+		{
+			name:      "pro rata shares rounding to nothing fill in time priority",
+			algorithm: ProRata{MinAllocation: 1},
+			orders:    restingOrders(1, 1, 1),
+			quantity:  2,
+			want:      map[int64]int64{1: 1, 2: 1},
+		},
+		{
+			name:      "pro rata takes the whole level",
+			algorithm: ProRata{MinAllocation: 1},
+			orders:    restingOrders(4, 2),
+			quantity:  8,
+			want:      map[int64]int64{1: 4, 2: 2},
+		},
+		{
+			name:      "pro rata drops shares below the minimum allocation",
+			algorithm: ProRata{MinAllocation: 2},
+			orders:    restingOrders(10, 3),
+			quantity:  6,
+			want:      map[int64]int64{1: 6},
+		},
+		{
+			name:      "pro rata rounds shares down to whole lots",
+			algorithm: ProRata{MinAllocation: 1, LotSize: 10},
+			orders:    restingOrders(60, 30, 10),
+			quantity:  50,
+			want:      map[int64]int64{1: 40, 2: 10},
+		},
+		{
+			name:      "pro rata counts the minimum allocation in lots",
+			algorithm: ProRata{MinAllocation: 2, LotSize: 10},
+			orders:    restingOrders(60, 30, 10),
+			quantity:  50,
+			want:      map[int64]int64{1: 50},
+		},
+		{
+			name:      "hybrid fills the top order first and shares the rest",
+			algorithm: Hybrid{MinAllocation: 1},
+			orders:    restingOrders(4, 6, 2),
+			quantity:  10,
+			want:      map[int64]int64{1: 4, 2: 5, 3: 1},
+		},
+		{
+			name:      "hybrid top order takes all of a small quantity",
+			algorithm: Hybrid{MinAllocation: 1},
+			orders:    restingOrders(10, 5),
+			quantity:  6,
+			want:      map[int64]int64{1: 6},
+		},
+		{
+			name:      "hybrid drops shares below the minimum allocation",
+			algorithm: Hybrid{MinAllocation: 3},
+			orders:    restingOrders(2, 8, 2),
+			quantity:  7,
+			want:      map[int64]int64{1: 2, 2: 5},
+		},
+		{
+			name:      "an empty level allocates nothing",
+			algorithm: Hybrid{MinAllocation: 1},
+			orders:    nil,
+			quantity:  5,
+			want:      map[int64]int64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := quantities(tt.algorithm.Allocate(tt.orders, tt.quantity))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Allocate(%d) = %v, want %v", tt.quantity, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package orderbook
+
+import (
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+)
+
+// Uncross returns the single price at which the crossed book trades the most, with the
+// volume trading there and the imbalance left over. Among prices trading the same volume
+// the smallest imbalance wins, then the side with the surplus pushes the price its way:
+// the highest price when buyers are left over, the lowest when sellers are. Prices left
+// tied go to the one closest to the last trade price, or the lowest without one. The
+// whole remaining quantity of iceberg orders counts, orders with a minimum quantity sit
+// the auction out.
+func (b *OrderBook) Uncross() types.Auction {
+	auction := types.Auction{PriceScale: b.PriceScale, QuantityScale: b.QuantityScale}
+
+	if !b.Crossed() {
+		return auction
+	}
+
+	// only prices between the best ask and the best bid can trade both sides
+	low, high := b.asks.levels[0].Price, b.bids.levels[0].Price
+	var prices []int64
+	for _, level := range b.bids.levels {
+		if level.Price >= low {
+			prices = append(prices, level.Price)
+		}
+	}
+	for _, level := range b.asks.levels {
+		if level.Price <= high {
+			if _, ok := b.bids.prices[level.Price]; !ok {
+				prices = append(prices, level.Price)
+			}
+		}
+	}
+
+	var best *uncrossing
+	for _, price := range prices {
+		candidate := b.uncrossAt(price)
+		if best == nil || b.betterUncross(candidate, *best) {
+			best = &candidate
+		}
+	}
+
+	auction.IndicativePrice = &best.price
+	auction.IndicativeVolume = min(best.demand, best.supply)
+	switch surplus := best.demand - best.supply; {
+	case surplus > 0:
+		auction.Imbalance, auction.ImbalanceSide = surplus, types.BUY
+	case surplus < 0:
+		auction.Imbalance, auction.ImbalanceSide = -surplus, types.SELL
+	}
+
+	return auction
+}
+
+// Crossed reports whether the best bid is at or above the best ask, hidden orders
+// included, so the book has something to uncross
+func (b *OrderBook) Crossed() bool {
+	return len(b.bids.levels) > 0 && len(b.asks.levels) > 0 && b.bids.levels[0].Price >= b.asks.levels[0].Price
+}
+
+// uncrossing is the quantity bid and offered at a candidate uncrossing price
+type uncrossing struct {
+	price  int64
+	demand int64
+	supply int64
+}
+
+func (u uncrossing) volume() int64 { return min(u.demand, u.supply) }
+
+func (u uncrossing) imbalance() int64 {
+	if u.demand > u.supply {
+		return u.demand - u.supply
+	}
+	return u.supply - u.demand
+}
+
+func (b *OrderBook) uncrossAt(price int64) uncrossing {
+	u := uncrossing{price: price}
+	for _, level := range b.bids.levels {
+		if level.Price < price {
+			break
+		}
+		u.demand += level.remaining()
+	}
+	for _, level := range b.asks.levels {
+		if level.Price > price {
+			break
+		}
+		u.supply += level.remaining()
+	}
+	return u
+}
+
+// betterUncross reports whether uncrossing at x is preferred over y
+func (b *OrderBook) betterUncross(x, y uncrossing) bool {
+	if x.volume() != y.volume() {
+		return x.volume() > y.volume()
+	}
+	if x.imbalance() != y.imbalance() {
+		return x.imbalance() < y.imbalance()
+	}
+
+	// market pressure, both prices leave the same side over
+	switch {
+	case x.demand > x.supply && y.demand > y.supply:
+		return x.price > y.price
+	case x.demand < x.supply && y.demand < y.supply:
+		return x.price < y.price
+	}
+
+	if b.lastPrice != nil {
+		dx, dy := distance(x.price, *b.lastPrice), distance(y.price, *b.lastPrice)
+		if dx != dy {
+			return dx < dy
+		}
+	}
+	return x.price < y.price
+}
+
+func distance(a, b int64) int64 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// remaining returns the whole quantity resting at the level that takes part in an
+// auction, hidden reserves included
+func (l *Level) remaining() int64 {
+	var quantity int64
+	for e := l.orders.Front(); e != nil; e = e.Next() {
+		if order := e.Value.(*types.Order); order.MinFill() == 0 {
+			quantity += order.Remaining
+		}
+	}
+	return quantity
+}
@@ -0,0 +1,157 @@
+package orderbook
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+)
+
+// limit is a limit order for the book tests without an id, each test numbers its orders
+// as it adds them
+func limit(side types.OrderSide, price, quantity int64) *types.Order {
+	return &types.Order{Side: side, OrderType: types.LIMIT, Price: &price, Quantity: quantity, Remaining: quantity}
+}
+
+func TestUncross(t *testing.T) {
+	iceberg := limit(types.BUY, 101, 10)
+	iceberg.DisplayQuantity, iceberg.VisibleRemaining = 2, 2
+	minQuantity := limit(types.SELL, 100, 5)
+	minQuantity.MinQuantity = 5
+
+	tests := []struct {
+		name      string
+		orders    []*types.Order
+		lastPrice *int64
+		// reduce lowers orders by id in place before the uncross, like a self-trade decrement
+		reduce map[int64]int64
+		// wantPrice 0 expects no uncrossing
+		wantPrice     int64
+		wantVolume    int64
+		wantImbalance int64
+		wantSide      types.OrderSide
+	}{
+		{
+			name:   "a book that isn't crossed doesn't uncross",
+			orders: []*types.Order{limit(types.BUY, 99, 5), limit(types.SELL, 100, 5)},
+		},
+		{
+			name: "the price trading the largest volume wins",
+			orders: []*types.Order{
+				limit(types.BUY, 102, 10), limit(types.BUY, 101, 5),
+				limit(types.SELL, 100, 4), limit(types.SELL, 101, 8), limit(types.SELL, 103, 6),
+			},
+			wantPrice: 101, wantVolume: 12, wantImbalance: 3, wantSide: types.BUY,
+		},
+		{
+			name: "among the same volume the smallest imbalance wins",
+			orders: []*types.Order{
+				limit(types.BUY, 102, 6),
+				limit(types.SELL, 100, 6), limit(types.SELL, 102, 3),
+			},
+			lastPrice: ptr(102),
+			wantPrice: 100, wantVolume: 6,
+		},
+		{
+			name:      "buyers left over push the price up",
+			orders:    []*types.Order{limit(types.BUY, 102, 10), limit(types.SELL, 100, 4)},
+			lastPrice: ptr(100),
+			wantPrice: 102, wantVolume: 4, wantImbalance: 6, wantSide: types.BUY,
+		},
+		{
+			name:      "sellers left over push the price down",
+			orders:    []*types.Order{limit(types.BUY, 102, 4), limit(types.SELL, 100, 10)},
+			lastPrice: ptr(102),
+			wantPrice: 100, wantVolume: 4, wantImbalance: 6, wantSide: types.SELL,
+		},
+		{
+			name:      "a balanced book uncrosses closest to the last price",
+			orders:    []*types.Order{limit(types.BUY, 102, 5), limit(types.SELL, 100, 5)},
+			lastPrice: ptr(105),
+			wantPrice: 102, wantVolume: 5,
+		},
+		{
+			name:      "a balanced book below the last price uncrosses at its lowest",
+			orders:    []*types.Order{limit(types.BUY, 102, 5), limit(types.SELL, 100, 5)},
+			lastPrice: ptr(99),
+			wantPrice: 100, wantVolume: 5,
+		},
+		{
+			name:      "without a last price the lowest price wins",
+			orders:    []*types.Order{limit(types.BUY, 102, 5), limit(types.SELL, 100, 5)},
+			wantPrice: 100, wantVolume: 5,
+		},
+		{
+			name: "a reduced order keeps its place and trades its reduced size",
+			orders: []*types.Order{
+				limit(types.BUY, 101, 6), limit(types.BUY, 101, 4),
+				limit(types.SELL, 100, 9),
+			},
+			reduce:    map[int64]int64{1: 4},
+			wantPrice: 100, wantVolume: 6, wantImbalance: 3, wantSide: types.SELL,
+		},
+		{
+			name:      "iceberg reserves count and minimum quantity orders sit out",
+			orders:    []*types.Order{iceberg, minQuantity, limit(types.SELL, 101, 8)},
+			wantPrice: 101, wantVolume: 8, wantImbalance: 2, wantSide: types.BUY,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			book := New("TEST")
+			for i, order := range tt.orders {
+				order.OrderID = int64(i + 1)
+				book.Add(order)
+			}
+			if tt.lastPrice != nil {
+				book.SetLastPrice(*tt.lastPrice)
+			}
+
+			before := levelOrder(book)
+			for orderID, quantity := range tt.reduce {
+				order, _ := book.Get(orderID)
+				book.Reduce(order, quantity)
+			}
+			if after := levelOrder(book); !reflect.DeepEqual(after, before) {
+				t.Fatalf("levels after Reduce() = %v, want %v", after, before)
+			}
+
+			auction := book.Uncross()
+			if tt.wantPrice == 0 {
+				if auction.IndicativePrice != nil {
+					t.Fatalf("Uncross() price = %d, want none", *auction.IndicativePrice)
+				}
+				return
+			}
+			if auction.IndicativePrice == nil {
+				t.Fatalf("Uncross() price = none, want %d", tt.wantPrice)
+			}
+			if *auction.IndicativePrice != tt.wantPrice || auction.IndicativeVolume != tt.wantVolume {
+				t.Errorf("Uncross() = %d x %d, want %d x %d", *auction.IndicativePrice, auction.IndicativeVolume, tt.wantPrice, tt.wantVolume)
+			}
+			if auction.Imbalance != tt.wantImbalance || auction.ImbalanceSide != tt.wantSide {
+				t.Errorf("Uncross() imbalance = %d %q, want %d %q", auction.Imbalance, auction.ImbalanceSide, tt.wantImbalance, tt.wantSide)
+			}
+		})
+	}
+}
+
+func ptr(price int64) *int64 {
+	return &price
+}
+
+// levelOrder lists the order ids of every level in queue order, the bids' levels first
+func levelOrder(book *OrderBook) [][]int64 {
+	var levels [][]int64
+	for _, side := range []types.OrderSide{types.BUY, types.SELL} {
+		for _, level := range book.Levels(side) {
+			var ids []int64
+			for _, order := range level.Orders() {
+				ids = append(ids, order.OrderID)
+			}
+			levels = append(levels, ids)
+		}
+	}
+	return levels
+}
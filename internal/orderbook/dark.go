@@ -0,0 +1,71 @@
+package orderbook
+
+import (
+	"container/heap"
+	"sort"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+)
+
+// The dark book keeps the resting dark orders of each side oldest first. They never show
+// in the order book and only trade with each other, at the midpoint of the lit book.
+
+func (b *OrderBook) darkSide(side types.OrderSide) *[]*types.Order {
+	if side == types.BUY {
+		return &b.darkBids
+	}
+	return &b.darkAsks
+}
+
+// AddDark puts a dark order in the dark book
+func (b *OrderBook) AddDark(order *types.Order) {
+	if order.Remaining <= 0 {
+		return
+	}
+	if _, ok := b.dark[order.OrderID]; ok {
+		return
+	}
+
+	orders := b.darkSide(order.Side)
+	i := sort.Search(len(*orders), func(i int) bool {
+		return (*orders)[i].OrderID > order.OrderID
+	})
+	*orders = append(*orders, nil)
+	copy((*orders)[i+1:], (*orders)[i:])
+	(*orders)[i] = order
+	b.dark[order.OrderID] = order
+
+	if order.ExpireAt != nil {
+		heap.Push(&b.expiries, expiry{at: *order.ExpireAt, orderID: order.OrderID})
+	}
+}
+
+// GetDark returns a resting dark order by its id
+func (b *OrderBook) GetDark(orderID int64) (*types.Order, bool) {
+	order, ok := b.dark[orderID]
+	return order, ok
+}
+
+// RemoveDark takes an order out of the dark book and returns it
+func (b *OrderBook) RemoveDark(orderID int64) *types.Order {
+	order, ok := b.dark[orderID]
+	if !ok {
+		return nil
+	}
+
+	orders := b.darkSide(order.Side)
+	for i, resting := range *orders {
+		if resting.OrderID == orderID {
+			*orders = append((*orders)[:i], (*orders)[i+1:]...)
+			break
+		}
+	}
+	delete(b.dark, orderID)
+
+	return order
+}
+
+// Dark returns the resting dark orders of a side, oldest first
+func (b *OrderBook) Dark(side types.OrderSide) []*types.Order {
+	return append([]*types.Order(nil), *b.darkSide(side)...)
+}
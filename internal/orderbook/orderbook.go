@@ -0,0 +1,549 @@
+package orderbook
+
+import (
+	"container/heap"
+	"container/list"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/decimal"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+)
+
+// Level holds the resting orders at a single price in arrival order, hidden orders
+// queue behind the displayed ones. Quantity is the displayed quantity, it leaves out
+// hidden orders and the hidden reserve of iceberg orders.
+type Level struct {
+	Price    int64
+	Quantity int64
+	orders   *list.List
+}
+
+// Front returns the oldest order resting at this level
+func (l *Level) Front() *types.Order {
+	if e := l.orders.Front(); e != nil {
+		return e.Value.(*types.Order)
+	}
+	return nil
+}
+
+// Orders returns the resting orders at this level, oldest first
+func (l *Level) Orders() []*types.Order {
+	orders := make([]*types.Order, 0, l.orders.Len())
+	for e := l.orders.Front(); e != nil; e = e.Next() {
+		orders = append(orders, e.Value.(*types.Order))
+	}
+	return orders
+}
+
+// push queues an order at the back of the level, a displayed order goes ahead of the
+// hidden ones
+func (l *Level) push(order *types.Order) *list.Element {
+	if order.Hidden {
+		return l.orders.PushBack(order)
+	}
+	if last := l.lastDisplayed(nil); last != nil {
+		return l.orders.InsertAfter(order, last)
+	}
+	return l.orders.PushFront(order)
+}
+
+// moveBack moves a displayed order behind the other displayed orders of the level
+func (l *Level) moveBack(elem *list.Element) {
+	if last := l.lastDisplayed(elem); last != nil {
+		l.orders.MoveAfter(elem, last)
+	} else {
+		l.orders.MoveToFront(elem)
+	}
+}
+
+// lastDisplayed returns the last displayed order of the level other than skip
+func (l *Level) lastDisplayed(skip *list.Element) *list.Element {
+	for e := l.orders.Back(); e != nil; e = e.Prev() {
+		if e != skip && !e.Value.(*types.Order).Hidden {
+			return e
+		}
+	}
+	return nil
+}
+
+// bookSide keeps the price levels of one side sorted best price first
+type bookSide struct {
+	side   types.OrderSide
+	levels []*Level
+	prices map[int64]*Level
+}
+
+func newBookSide(side types.OrderSide) *bookSide {
+	return &bookSide{
+		side:   side,
+		prices: make(map[int64]*Level),
+	}
+}
+
+// better reports whether price a has priority over price b on this side
+func (s *bookSide) better(a, b int64) bool {
+	if s.side == types.BUY {
+		return a > b
+	}
+	return a < b
+}
+
+func (s *bookSide) search(price int64) int {
+	return sort.Search(len(s.levels), func(i int) bool {
+		return !s.better(s.levels[i].Price, price)
+	})
+}
+
+func (s *bookSide) getOrCreateLevel(price int64) *Level {
+	if level, ok := s.prices[price]; ok {
+		return level
+	}
+
+	level := &Level{Price: price, orders: list.New()}
+	i := s.search(price)
+	s.levels = append(s.levels, nil)
+	copy(s.levels[i+1:], s.levels[i:])
+	s.levels[i] = level
+	s.prices[price] = level
+
+	return level
+}
+
+func (s *bookSide) removeLevel(level *Level) {
+	i := s.search(level.Price)
+	if i < len(s.levels) && s.levels[i] == level {
+		s.levels = append(s.levels[:i], s.levels[i+1:]...)
+	}
+	delete(s.prices, level.Price)
+}
+
+type entry struct {
+	level *Level
+	elem  *list.Element
+}
+
+type expiry struct {
+	at      time.Time
+	orderID int64
+}
+
+// expiryHeap orders expiring orders soonest first, entries of orders that
+// already left the book are dropped when they surface. An order added again,
+// like a requeued one, has an entry per add.
+type expiryHeap []expiry
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h expiryHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *expiryHeap) Push(x any)        { *h = append(*h, x.(expiry)) }
+func (h *expiryHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// OrderBook keeps the resting limit orders of one symbol with price-time priority.
+// It is not safe for concurrent use, a book is owned by its symbol's sequencer.
+type OrderBook struct {
+	Symbol string
+	Phase  types.TradingPhase
+	// PhaseUntil is when the phase ends by itself, nil when it waits for an operator. In
+	// batch matching it is the end of the running batch.
+	PhaseUntil *time.Time
+	// Mode is the phase the symbol trades in while open, continuous or batch
+	Mode types.TradingPhase
+	// TickSize is the instrument's price increment and LotSize its quantity increment,
+	// MinQuantity and MaxQuantity bound an order's size, a MaxQuantity of 0 leaves it
+	// unbounded. Prices and quantities are counted in units of the instrument's scales.
+	TickSize      int64
+	LotSize       int64
+	MinQuantity   int64
+	MaxQuantity   int64
+	PriceScale    int
+	QuantityScale int
+
+	bids     *bookSide
+	asks     *bookSide
+	orders   map[int64]*entry
+	expiries expiryHeap
+	// resting pegged orders, their price follows the top of the book
+	pegged map[int64]*types.Order
+
+	// dark book of the resting dark orders
+	darkBids []*types.Order
+	darkAsks []*types.Order
+	dark     map[int64]*types.Order
+
+	// trigger book of the pending stop orders
+	buyStops  *stopQueue
+	sellStops *stopQueue
+	stops     map[int64]*types.Order
+	// trailing stops whose stop price moved since TrailedStops was last called
+	trailed map[int64]*types.Order
+	// orders opened during matching that start working once it is done
+	activated []int64
+
+	lastPrice *int64
+	// last trade price before the trading day starting at closeDay
+	previousClose *int64
+	closeDay      time.Time
+	// the latest trade prices, oldest first, at most window of them
+	prices []int64
+	window int
+}
+
+func New(symbol string) *OrderBook {
+	return &OrderBook{
+		Symbol:    symbol,
+		Phase:     types.CONTINUOUS,
+		Mode:      types.CONTINUOUS,
+		bids:      newBookSide(types.BUY),
+		asks:      newBookSide(types.SELL),
+		orders:    make(map[int64]*entry),
+		pegged:    make(map[int64]*types.Order),
+		dark:      make(map[int64]*types.Order),
+		buyStops:  &stopQueue{side: types.BUY},
+		sellStops: &stopQueue{side: types.SELL},
+		stops:     make(map[int64]*types.Order),
+		trailed:   make(map[int64]*types.Order),
+	}
+}
+
+// Empty reports whether no order rests in the book or the dark book or waits in the
+// trigger book
+func (b *OrderBook) Empty() bool {
+	return len(b.orders) == 0 && len(b.stops) == 0 && len(b.dark) == 0
+}
+
+// LastPrice returns the price of the last trade, false when the symbol hasn't traded yet
+func (b *OrderBook) LastPrice() (int64, bool) {
+	if b.lastPrice == nil {
+		return 0, false
+	}
+	return *b.lastPrice, true
+}
+
+// SetLastPrice records the price of the last trade and trails the trailing stops behind it
+func (b *OrderBook) SetLastPrice(price int64) {
+	b.lastPrice = &price
+	b.trailStops(price)
+
+	if b.window > 0 {
+		b.prices = append(b.prices, price)
+		if len(b.prices) > b.window {
+			b.prices = b.prices[len(b.prices)-b.window:]
+		}
+	}
+}
+
+// PreviousClose returns the last trade price before the trading day starting at day, false
+// when it isn't known for that day yet
+func (b *OrderBook) PreviousClose(day time.Time) (*int64, bool) {
+	if !b.closeDay.Equal(day) {
+		return nil, false
+	}
+	return b.previousClose, true
+}
+
+// SetPreviousClose records the last trade price before the trading day starting at day,
+// nil when the symbol hadn't traded by then
+func (b *OrderBook) SetPreviousClose(day time.Time, price *int64) {
+	b.closeDay = day
+	b.previousClose = price
+}
+
+// SetPriceWindow sets how many of the latest trade prices PriceRange looks at
+func (b *OrderBook) SetPriceWindow(trades int) {
+	b.window = trades
+	if len(b.prices) > trades {
+		b.prices = b.prices[len(b.prices)-trades:]
+	}
+}
+
+// PriceRange returns the lowest and highest of the latest trade prices, false before the
+// first trade of the window
+func (b *OrderBook) PriceRange() (low, high int64, ok bool) {
+	if len(b.prices) == 0 {
+		return 0, 0, false
+	}
+
+	low, high = b.prices[0], b.prices[0]
+	for _, price := range b.prices[1:] {
+		low, high = min(low, price), max(high, price)
+	}
+	return low, high, true
+}
+
+// ClearPrices forgets the latest trade prices, the window starts over with the next trade
+func (b *OrderBook) ClearPrices() {
+	b.prices = nil
+}
+
+func (b *OrderBook) bookSide(side types.OrderSide) *bookSide {
+	if side == types.BUY {
+		return b.bids
+	}
+	return b.asks
+}
+
+// Reset replaces the content of the book, the dark book and the trigger book, orders are
+// expected oldest first
+func (b *OrderBook) Reset(orders []*types.Order) {
+	b.bids = newBookSide(types.BUY)
+	b.asks = newBookSide(types.SELL)
+	b.orders = make(map[int64]*entry)
+	b.pegged = make(map[int64]*types.Order)
+	b.darkBids, b.darkAsks = nil, nil
+	b.dark = make(map[int64]*types.Order)
+	b.expiries = nil
+	b.buyStops = &stopQueue{side: types.BUY}
+	b.sellStops = &stopQueue{side: types.SELL}
+	b.stops = make(map[int64]*types.Order)
+	b.trailed = make(map[int64]*types.Order)
+	b.activated = nil
+
+	for _, order := range orders {
+		switch {
+		case order.IsPendingStop():
+			b.AddStop(order)
+		case order.Dark:
+			b.AddDark(order)
+		default:
+			b.Add(order)
+		}
+	}
+}
+
+// ResetSide replaces the orders of one side of the book, orders are expected in priority
+// order. The dark book stays as it is.
+func (b *OrderBook) ResetSide(side types.OrderSide, orders []*types.Order) {
+	dropped := make(map[int64]bool)
+	for _, level := range b.bookSide(side).levels {
+		for _, order := range level.Orders() {
+			dropped[order.OrderID] = true
+			delete(b.orders, order.OrderID)
+			delete(b.pegged, order.OrderID)
+		}
+	}
+
+	// the reloaded orders push their expiries again
+	b.expiries = slices.DeleteFunc(b.expiries, func(e expiry) bool {
+		return dropped[e.orderID]
+	})
+	heap.Init(&b.expiries)
+
+	if side == types.BUY {
+		b.bids = newBookSide(types.BUY)
+	} else {
+		b.asks = newBookSide(types.SELL)
+	}
+
+	for _, order := range orders {
+		if order.Side == side && !order.IsPendingStop() && !order.Dark {
+			b.Add(order)
+		}
+	}
+}
+
+// Add appends a resting order at the back of its price level, behind the displayed
+// orders when it is hidden and ahead of the hidden ones otherwise
+func (b *OrderBook) Add(order *types.Order) {
+	if order.Price == nil || order.Remaining <= 0 {
+		return
+	}
+	if _, ok := b.orders[order.OrderID]; ok {
+		return
+	}
+
+	level := b.bookSide(order.Side).getOrCreateLevel(*order.Price)
+	elem := level.push(order)
+	level.Quantity += order.Displayed()
+
+	b.orders[order.OrderID] = &entry{level: level, elem: elem}
+	if order.IsPegged() {
+		b.pegged[order.OrderID] = order
+	}
+
+	if order.ExpireAt != nil {
+		heap.Push(&b.expiries, expiry{at: *order.ExpireAt, orderID: order.OrderID})
+	}
+}
+
+// Activate queues an order to start working after the current matching is done
+func (b *OrderBook) Activate(orderID int64) {
+	b.activated = append(b.activated, orderID)
+}
+
+// TakeActivated returns the queued orders in activation order and empties the queue
+func (b *OrderBook) TakeActivated() []int64 {
+	activated := b.activated
+	b.activated = nil
+	return activated
+}
+
+// Expired returns the resting and pending stop orders whose expiry is at or before
+// now, each once. They stay in the book until removed.
+func (b *OrderBook) Expired(now time.Time) []*types.Order {
+	var orders []*types.Order
+	seen := make(map[int64]bool)
+	for b.expiries.Len() > 0 && !now.Before(b.expiries[0].at) {
+		e := heap.Pop(&b.expiries).(expiry)
+		if seen[e.orderID] {
+			continue
+		}
+		seen[e.orderID] = true
+
+		if order, ok := b.Get(e.orderID); ok {
+			orders = append(orders, order)
+		} else if order, ok := b.GetStop(e.orderID); ok {
+			orders = append(orders, order)
+		} else if order, ok := b.GetDark(e.orderID); ok {
+			orders = append(orders, order)
+		}
+	}
+	return orders
+}
+
+// Get returns a resting order by its id
+func (b *OrderBook) Get(orderID int64) (*types.Order, bool) {
+	e, ok := b.orders[orderID]
+	if !ok {
+		return nil, false
+	}
+	return e.elem.Value.(*types.Order), true
+}
+
+// Remove takes an order out of the book and returns it
+func (b *OrderBook) Remove(orderID int64) *types.Order {
+	e, ok := b.orders[orderID]
+	if !ok {
+		return nil
+	}
+
+	order := e.level.orders.Remove(e.elem).(*types.Order)
+	e.level.Quantity -= order.Displayed()
+	if e.level.orders.Len() == 0 {
+		b.bookSide(order.Side).removeLevel(e.level)
+	}
+	delete(b.orders, orderID)
+	delete(b.pegged, orderID)
+
+	return order
+}
+
+// Pegged returns the resting pegged orders, oldest first
+func (b *OrderBook) Pegged() []*types.Order {
+	orders := make([]*types.Order, 0, len(b.pegged))
+	for _, order := range b.pegged {
+		orders = append(orders, order)
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].OrderID < orders[j].OrderID })
+	return orders
+}
+
+// Fill reduces the remaining quantity of a resting order and drops it from the book once
+// filled. An iceberg whose shown slice is used up shows the next slice of its reserve
+// from behind the other displayed orders, Fill reports when that happened.
+func (b *OrderBook) Fill(order *types.Order, quantity int64) (refilled bool) {
+	shown := order.Displayed()
+
+	order.Remaining -= quantity
+	if order.IsIceberg() {
+		order.VisibleRemaining -= quantity
+	}
+
+	e, ok := b.orders[order.OrderID]
+	if !ok {
+		return false
+	}
+
+	e.level.Quantity += order.Displayed() - shown
+	if order.Remaining <= 0 {
+		b.Remove(order.OrderID)
+		return false
+	}
+
+	if order.IsIceberg() && order.VisibleRemaining <= 0 {
+		order.VisibleRemaining = min(order.DisplayQuantity, order.Remaining)
+		e.level.Quantity += order.VisibleRemaining
+		e.level.moveBack(e.elem)
+		return true
+	}
+
+	return false
+}
+
+// Reduce lowers the remaining quantity of a resting order keeping its place in the queue
+func (b *OrderBook) Reduce(order *types.Order, quantity int64) {
+	shown := order.Displayed()
+
+	order.Remaining -= quantity
+	if order.IsIceberg() {
+		order.VisibleRemaining = min(order.VisibleRemaining, order.Remaining)
+	}
+
+	if e, ok := b.orders[order.OrderID]; ok {
+		e.level.Quantity += order.Displayed() - shown
+	}
+}
+
+// BestLevel returns the best price level of a side, or nil when the side is empty
+func (b *OrderBook) BestLevel(side types.OrderSide) *Level {
+	levels := b.bookSide(side).levels
+	if len(levels) == 0 {
+		return nil
+	}
+	return levels[0]
+}
+
+// Best returns the order with the highest priority on a side
+func (b *OrderBook) Best(side types.OrderSide) *types.Order {
+	if level := b.BestLevel(side); level != nil {
+		return level.Front()
+	}
+	return nil
+}
+
+// Levels returns the price levels of a side, best price first
+func (b *OrderBook) Levels(side types.OrderSide) []*Level {
+	return b.bookSide(side).levels
+}
+
+// Snapshot aggregates the displayed orders into price levels, bids highest first and asks
+// lowest first. During an auction call or a batch it carries the indicative uncrossing.
+func (b *OrderBook) Snapshot() types.OrderBookSnapshot {
+	snapshot := types.OrderBookSnapshot{
+		Symbol: b.Symbol,
+		Bids:   make([]types.OrderBookEntry, 0, len(b.bids.levels)),
+		Asks:   make([]types.OrderBookEntry, 0, len(b.asks.levels)),
+	}
+
+	// levels holding only hidden orders don't show
+	for _, level := range b.bids.levels {
+		if level.Quantity > 0 {
+			snapshot.Bids = append(snapshot.Bids, b.entry(level))
+		}
+	}
+	for _, level := range b.asks.levels {
+		if level.Quantity > 0 {
+			snapshot.Asks = append(snapshot.Asks, b.entry(level))
+		}
+	}
+
+	if b.Phase == types.AUCTION || b.Phase == types.BATCH {
+		auction := b.Uncross()
+		snapshot.Auction = &auction
+	}
+
+	return snapshot
+}
+
+func (b *OrderBook) entry(level *Level) types.OrderBookEntry {
+	return types.OrderBookEntry{
+		Price:    decimal.New(level.Price, b.PriceScale),
+		Quantity: decimal.New(level.Quantity, b.QuantityScale),
+	}
+}
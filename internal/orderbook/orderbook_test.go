@@ -0,0 +1,41 @@
+package orderbook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+)
+
+func TestExpired(t *testing.T) {
+	now := time.Now()
+	expiring := func(id int64, side types.OrderSide) *types.Order {
+		order := limit(side, 100, 5)
+		order.OrderID, order.ExpireAt = id, &now
+		return order
+	}
+
+	book := New("TEST")
+	book.Add(expiring(1, types.BUY))
+	book.Add(expiring(2, types.SELL))
+
+	// a requeued order and a reloaded side add their orders again
+	book.Add(book.Remove(1))
+	book.ResetSide(types.SELL, []*types.Order{expiring(2, types.SELL)})
+	book.ResetSide(types.SELL, []*types.Order{expiring(2, types.SELL)})
+
+	if len(book.expiries) != 3 {
+		t.Errorf("expiries = %d entries, want 3 without the old entries of the reloaded side", len(book.expiries))
+	}
+
+	var got []int64
+	for _, order := range book.Expired(now) {
+		got = append(got, order.OrderID)
+	}
+	if len(got) != 2 {
+		t.Errorf("Expired() = %v, want each order once", got)
+	}
+	if expired := book.Expired(now); len(expired) != 0 {
+		t.Errorf("Expired() again = %d orders, want none", len(expired))
+	}
+}
@@ -0,0 +1,138 @@
+package orderbook
+
+import (
+	"container/heap"
+	"sort"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+)
+
+// stopQueue keeps the pending stop orders of one side in trigger order: buy stops
+// lowest stop price first, sell stops highest first, oldest first on equal prices
+type stopQueue struct {
+	side   types.OrderSide
+	orders []*types.Order
+}
+
+// crossed reports whether a trade at price triggers a stop at stopPrice
+func (q *stopQueue) crossed(stopPrice, price int64) bool {
+	if q.side == types.BUY {
+		return price >= stopPrice
+	}
+	return price <= stopPrice
+}
+
+func (q *stopQueue) add(order *types.Order) {
+	i := sort.Search(len(q.orders), func(i int) bool {
+		if q.side == types.BUY {
+			return *q.orders[i].StopPrice > *order.StopPrice
+		}
+		return *q.orders[i].StopPrice < *order.StopPrice
+	})
+
+	q.orders = append(q.orders, nil)
+	copy(q.orders[i+1:], q.orders[i:])
+	q.orders[i] = order
+}
+
+func (q *stopQueue) remove(orderID int64) {
+	for i, order := range q.orders {
+		if order.OrderID == orderID {
+			q.orders = append(q.orders[:i], q.orders[i+1:]...)
+			return
+		}
+	}
+}
+
+// AddStop puts a stop order in the trigger book
+func (b *OrderBook) AddStop(order *types.Order) {
+	if order.StopPrice == nil {
+		return
+	}
+	if _, ok := b.stops[order.OrderID]; ok {
+		return
+	}
+
+	b.stopQueue(order.Side).add(order)
+	b.stops[order.OrderID] = order
+
+	if order.ExpireAt != nil {
+		heap.Push(&b.expiries, expiry{at: *order.ExpireAt, orderID: order.OrderID})
+	}
+}
+
+// GetStop returns a pending stop order by its id
+func (b *OrderBook) GetStop(orderID int64) (*types.Order, bool) {
+	order, ok := b.stops[orderID]
+	return order, ok
+}
+
+// RemoveStop takes a stop order out of the trigger book and returns it
+func (b *OrderBook) RemoveStop(orderID int64) *types.Order {
+	order, ok := b.stops[orderID]
+	if !ok {
+		return nil
+	}
+
+	b.stopQueue(order.Side).remove(orderID)
+	delete(b.stops, orderID)
+	delete(b.trailed, orderID)
+
+	return order
+}
+
+// TriggerStops takes every stop order crossed by a trade at price out of the trigger book
+func (b *OrderBook) TriggerStops(price int64) []*types.Order {
+	var triggered []*types.Order
+	for _, q := range []*stopQueue{b.buyStops, b.sellStops} {
+		n := 0
+		for n < len(q.orders) && q.crossed(*q.orders[n].StopPrice, price) {
+			n++
+		}
+
+		for _, order := range q.orders[:n] {
+			delete(b.stops, order.OrderID)
+			triggered = append(triggered, order)
+		}
+		q.orders = append([]*types.Order(nil), q.orders[n:]...)
+	}
+	return triggered
+}
+
+// trailStops moves the trailing stops behind a trade at price, keeping the trigger order
+func (b *OrderBook) trailStops(price int64) {
+	for _, q := range []*stopQueue{b.buyStops, b.sellStops} {
+		var moved []*types.Order
+		for _, order := range q.orders {
+			if order.IsTrailing() && order.Trail(price) {
+				moved = append(moved, order)
+			}
+		}
+
+		for _, order := range moved {
+			q.remove(order.OrderID)
+			q.add(order)
+			b.trailed[order.OrderID] = order
+		}
+	}
+}
+
+// TrailedStops returns the trailing stops whose stop price moved since the last call
+func (b *OrderBook) TrailedStops() []*types.Order {
+	orders := make([]*types.Order, 0, len(b.trailed))
+	for _, order := range b.trailed {
+		orders = append(orders, order)
+	}
+	clear(b.trailed)
+
+	// a stable order keeps row locks taken in the same order across instances
+	sort.Slice(orders, func(i, j int) bool { return orders[i].OrderID < orders[j].OrderID })
+	return orders
+}
+
+func (b *OrderBook) stopQueue(side types.OrderSide) *stopQueue {
+	if side == types.BUY {
+		return b.buyStops
+	}
+	return b.sellStops
+}
@@ -0,0 +1,111 @@
+package sequencer
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var ErrClosed = errors.New("sequencer is closed")
+
+type command struct {
+	fn   func() error
+	done chan error
+}
+
+// Sequencer runs submitted commands one at a time per key, each key is
+// served by its own goroutine so commands of different keys run in parallel
+type Sequencer struct {
+	mu      sync.RWMutex
+	queues  map[string]chan command
+	size    int
+	closed  bool
+	senders sync.WaitGroup
+	workers sync.WaitGroup
+}
+
+// New creates a sequencer whose per key queues buffer up to size commands
+func New(size int) *Sequencer {
+	return &Sequencer{
+		queues: make(map[string]chan command),
+		size:   size,
+	}
+}
+
+// Submit queues fn behind every command already submitted for key and
+// blocks until it has run, returning its error
+func (s *Sequencer) Submit(key string, fn func() error) error {
+	cmd := command{fn: fn, done: make(chan error, 1)}
+
+	// a sender registers itself under the read lock and sends without it, Close
+	// waits for the registered senders before it closes the queues
+	s.mu.RLock()
+	if s.closed {
+		s.mu.RUnlock()
+		return ErrClosed
+	}
+	queue, ok := s.queues[key]
+	if !ok {
+		s.mu.RUnlock()
+		s.startQueue(key)
+		return s.Submit(key, fn)
+	}
+	s.senders.Add(1)
+	s.mu.RUnlock()
+
+	queue <- cmd
+	s.senders.Done()
+
+	return <-cmd.done
+}
+
+func (s *Sequencer) startQueue(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.queues[key]; ok || s.closed {
+		return
+	}
+
+	queue := make(chan command, s.size)
+	s.queues[key] = queue
+	s.workers.Add(1)
+	go s.run(queue)
+}
+
+// Close stops accepting commands and waits for the queued ones to finish
+func (s *Sequencer) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	// the workers keep draining the queues so blocked senders get through
+	s.senders.Wait()
+	for _, queue := range s.queues {
+		close(queue)
+	}
+	s.workers.Wait()
+}
+
+func (s *Sequencer) run(queue chan command) {
+	defer s.workers.Done()
+
+	for cmd := range queue {
+		cmd.done <- execute(cmd.fn)
+	}
+}
+
+// execute runs a command, turning a panic into an error so the key keeps being served
+func execute(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("command panicked: %v", r)
+		}
+	}()
+
+	return fn()
+}
@@ -2,23 +2,147 @@ package mysql
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 
 	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/config"
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/decimal"
 	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/storage"
 	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
 )
 
+// TiDB reports a write conflict of an optimistic transaction at commit, MySQL a deadlock
+const (
+	errCodeDeadlock      = 1213
+	errCodeWriteConflict = 9007
+	// errCodeDuplicateEntry is a duplicate primary or unique key
+	errCodeDuplicateEntry = 1062
+)
+
+// queryer is implemented by both *sql.DB and *sql.Tx
+type queryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// orderColumns lists the columns read into a types.Order, in scanOrder order
+const orderColumns = `order_id, symbol, account_id, side, type, price, quantity, remaining, status,
+	time_in_force, expire_at, stop_price, display_quantity, visible_remaining, post_only, stp_mode, max_slippage_bps, protection_price, trail_amount, trail_percent, limit_offset, group_id, reason,
+	quote_quantity, quote_remaining, min_quantity, all_or_none, hidden, dark, peg_type, peg_offset, peg_limit, price_scale, quantity_scale, created_at, updated_at`
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanOrder(row scanner) (*types.Order, error) {
+	var (
+		order                                                 types.Order
+		price, stopPrice, protectionPrice, pegType, pegLimit  sql.NullString
+		quantity, remaining, displayQuantity, visible         string
+		trailAmount, trailPercent, limitOffset, quoteQuantity string
+		quoteRemaining, minQuantity, pegOffset                string
+	)
+	err := row.Scan(&order.OrderID, &order.Symbol, &order.AccountID, &order.Side, &order.OrderType, &price, &quantity, &remaining, &order.Status,
+		&order.TimeInForce, &order.ExpireAt, &stopPrice, &displayQuantity, &visible,
+		&order.PostOnly, &order.STPMode, &order.MaxSlippageBps, &protectionPrice,
+		&trailAmount, &trailPercent, &limitOffset, &order.GroupID, &order.Reason,
+		&quoteQuantity, &quoteRemaining, &minQuantity, &order.AllOrNone, &order.Hidden, &order.Dark,
+		&pegType, &pegOffset, &pegLimit, &order.PriceScale, &order.QuantityScale, &order.CreatedAt, &order.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	var units unitsReader
+	order.Price = units.nullable(price, order.PriceScale)
+	order.StopPrice = units.nullable(stopPrice, order.PriceScale)
+	order.ProtectionPrice = units.nullable(protectionPrice, order.PriceScale)
+	order.TrailAmount = units.read(trailAmount, order.PriceScale)
+	order.TrailPercentBps = units.read(trailPercent, 2)
+	order.LimitOffset = units.read(limitOffset, order.PriceScale)
+	order.PegType = types.PegType(pegType.String)
+	order.PegOffset = units.read(pegOffset, order.PriceScale)
+	order.PegLimit = units.nullable(pegLimit, order.PriceScale)
+	order.QuoteQuantity = units.read(quoteQuantity, order.PriceScale)
+	order.QuoteRemaining = units.read(quoteRemaining, order.PriceScale)
+	order.Quantity = units.read(quantity, order.QuantityScale)
+	order.Remaining = units.read(remaining, order.QuantityScale)
+	order.DisplayQuantity = units.read(displayQuantity, order.QuantityScale)
+	order.VisibleRemaining = units.read(visible, order.QuantityScale)
+	order.MinQuantity = units.read(minQuantity, order.QuantityScale)
+	if units.err != nil {
+		return nil, fmt.Errorf("order %d: %w", order.OrderID, units.err)
+	}
+	return &order, nil
+}
+
+// Prices and quantities are stored as DECIMAL values, the engine counts them in units of
+// the price_scale and quantity_scale stored with them
+
+// decimalValue returns units of scale as the text of a DECIMAL value
+func decimalValue(units int64, scale int) string {
+	return decimal.New(units, scale).String()
+}
+
+// nullDecimalValue returns *units of scale as the text of a DECIMAL value, NULL for nil
+func nullDecimalValue(units *int64, scale int) any {
+	if units == nil {
+		return nil
+	}
+	return decimalValue(*units, scale)
+}
+
+// unitsReader converts the DECIMAL values of a row to units, it keeps the first error
+type unitsReader struct {
+	err error
+}
+
+func (u *unitsReader) read(value string, scale int) int64 {
+	if u.err != nil {
+		return 0
+	}
+
+	d, err := decimal.Parse(value)
+	if err != nil {
+		u.err = err
+		return 0
+	}
+	units, err := d.Units(scale)
+	if err != nil {
+		u.err = err
+		return 0
+	}
+	return units
+}
+
+func (u *unitsReader) nullable(value sql.NullString, scale int) *int64 {
+	if !value.Valid {
+		return nil
+	}
+	units := u.read(value.String, scale)
+	return &units
+}
+
 // mysqlTx implements the storage.Tx interface
 type mysqlTx struct {
 	tx *sql.Tx
 }
 
 func (m *mysqlTx) Commit() error {
-	return m.tx.Commit()
+	return conflictError(m.tx.Commit())
+}
+
+// conflictError marks errors caused by concurrent transactions so callers can retry
+func conflictError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && (mysqlErr.Number == errCodeWriteConflict || mysqlErr.Number == errCodeDeadlock) {
+		return fmt.Errorf("%w: %v", storage.ErrWriteConflict, err)
+	}
+	return err
 }
 
 func (m *mysqlTx) Rollback() error {
@@ -81,14 +205,241 @@ func New(cfg *config.Config) (*Mysql, error) {
 		return nil, fmt.Errorf("failed to create 'trades' table: %w", err)
 	}
 
+	_, err = db.Exec(
+		`CREATE TABLE IF NOT EXISTS order_groups (
+            group_id BIGINT PRIMARY KEY AUTO_INCREMENT,
+            type ENUM('oco') NOT NULL,
+            on_fill ENUM('cancel', 'reduce') NOT NULL DEFAULT 'cancel',
+            created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+        )`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create 'order_groups' table: %w", err)
+	}
+
+	_, err = db.Exec(
+		`CREATE TABLE IF NOT EXISTS trading_phases (
+            symbol VARCHAR(20) PRIMARY KEY,
+            phase ENUM('continuous', 'auction') NOT NULL,
+            updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+        )`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create 'trading_phases' table: %w", err)
+	}
+
+	var instrumentsExist bool
+	err = db.QueryRow(`SELECT COUNT(*) > 0 FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'instruments'`).
+		Scan(&instrumentsExist)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up 'instruments' table: %w", err)
+	}
+
+	_, err = db.Exec(
+		`CREATE TABLE IF NOT EXISTS instruments (
+            symbol VARCHAR(20) PRIMARY KEY,
+            base_asset VARCHAR(16) NOT NULL DEFAULT '',
+            quote_asset VARCHAR(16) NOT NULL DEFAULT '',
+            tick_size BIGINT NOT NULL DEFAULT 1,
+            lot_size BIGINT NOT NULL DEFAULT 1,
+            min_quantity BIGINT NOT NULL DEFAULT 1,
+            max_quantity BIGINT NOT NULL DEFAULT 0,
+            price_scale INT NOT NULL DEFAULT 0,
+            status ENUM('active', 'inactive') NOT NULL DEFAULT 'active',
+            created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+            updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+        )`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create 'instruments' table: %w", err)
+	}
+
+	// symbols traded before the registry existed are listed once, so their books keep working
+	if !instrumentsExist {
+		_, err = db.Exec(`INSERT IGNORE INTO instruments (symbol, tick_size) SELECT DISTINCT symbol, ? FROM orders`, cfg.Engine.TickSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the traded symbols as instruments: %w", err)
+		}
+	}
+
+	// prices were stored as units of the instrument's price scale before they became decimals
+	scaled, err := columnExists(db, "orders", "price_scale")
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up 'orders.price_scale' column: %w", err)
+	}
+
+	// orders placed before priority_at existed keep the time priority of their creation
+	prioritized, err := columnExists(db, "orders", "priority_at")
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up 'orders.priority_at' column: %w", err)
+	}
+
+	// bring tables created by earlier versions up to date
+	for _, column := range columns {
+		if err := syncColumn(db, column); err != nil {
+			return nil, fmt.Errorf("failed to migrate column '%s.%s': %w", column.table, column.name, err)
+		}
+	}
+
+	if !scaled {
+		if err := scalePrices(db); err != nil {
+			return nil, fmt.Errorf("failed to convert prices to decimals: %w", err)
+		}
+	}
+
+	if !prioritized {
+		if _, err := db.Exec(`UPDATE orders SET priority_at = created_at`); err != nil {
+			return nil, fmt.Errorf("failed to backfill 'orders.priority_at': %w", err)
+		}
+	}
+
 	return &Mysql{DB: db}, nil
 }
 
+// column is a column changed or added after the initial schema
+type column struct {
+	table      string
+	name       string
+	definition string
+}
+
+var columns = []column{
+	{"orders", "type", "ENUM('limit', 'market', 'stop', 'stop_limit', 'trailing_stop', 'trailing_stop_limit', 'pegged') NOT NULL"},
+	{"orders", "status", "ENUM('open', 'filled', 'cancelled', 'partial', 'expired', 'triggered', 'rejected') NOT NULL"},
+	{"orders", "time_in_force", "ENUM('gtc', 'ioc', 'fok', 'day', 'gtd') NOT NULL DEFAULT 'gtc'"},
+	{"orders", "expire_at", "TIMESTAMP NULL"},
+	{"orders", "price", "DECIMAL(38,18)"},
+	{"orders", "quantity", "DECIMAL(38,18) NOT NULL"},
+	{"orders", "remaining", "DECIMAL(38,18) NOT NULL"},
+	{"orders", "stop_price", "DECIMAL(38,18)"},
+	{"orders", "display_quantity", "DECIMAL(38,18) NOT NULL DEFAULT 0"},
+	{"orders", "visible_remaining", "DECIMAL(38,18) NOT NULL DEFAULT 0"},
+	// time priority within a price level, moved to the back when an iceberg shows a new slice
+	{"orders", "priority_at", "TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6)"},
+	{"orders", "post_only", "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{"orders", "reason", "VARCHAR(255) NOT NULL DEFAULT ''"},
+	{"orders", "account_id", "VARCHAR(64) NOT NULL DEFAULT ''"},
+	{"orders", "stp_mode", "ENUM('cancel_newest', 'cancel_oldest', 'cancel_both', 'decrement_cancel') NOT NULL DEFAULT 'cancel_newest'"},
+	{"orders", "max_slippage_bps", "INT NOT NULL DEFAULT 0"},
+	{"orders", "protection_price", "DECIMAL(38,18)"},
+	{"orders", "trail_amount", "DECIMAL(38,18) NOT NULL DEFAULT 0"},
+	// percent with two decimals, the engine counts it in basis points
+	{"orders", "trail_percent", "DECIMAL(5,2) NOT NULL DEFAULT 0"},
+	{"orders", "limit_offset", "DECIMAL(38,18) NOT NULL DEFAULT 0"},
+	{"orders", "group_id", "BIGINT"},
+	// amount of the quote currency a buy order spends, in the order's price scale
+	{"orders", "quote_quantity", "DECIMAL(38,18) NOT NULL DEFAULT 0"},
+	{"orders", "quote_remaining", "DECIMAL(38,18) NOT NULL DEFAULT 0"},
+	// the least quantity a single match has to trade, all_or_none asks for the whole remainder
+	{"orders", "min_quantity", "DECIMAL(38,18) NOT NULL DEFAULT 0"},
+	{"orders", "all_or_none", "BOOLEAN NOT NULL DEFAULT FALSE"},
+	// hidden orders trade but don't show in the order book
+	{"orders", "hidden", "BOOLEAN NOT NULL DEFAULT FALSE"},
+	// what the price of a pegged order follows, price holds its current price
+	{"orders", "peg_type", "ENUM('primary', 'market', 'midpoint')"},
+	{"orders", "peg_offset", "DECIMAL(38,18) NOT NULL DEFAULT 0"},
+	{"orders", "peg_limit", "DECIMAL(38,18)"},
+	// dark orders rest in the dark book and only trade with each other
+	{"orders", "dark", "BOOLEAN NOT NULL DEFAULT FALSE"},
+	// decimals of the order's prices and quantities, those of its instrument
+	{"orders", "price_scale", "TINYINT NOT NULL DEFAULT 0"},
+	{"orders", "quantity_scale", "TINYINT NOT NULL DEFAULT 0"},
+	{"trades", "price", "DECIMAL(38,18) NOT NULL"},
+	{"trades", "quantity", "DECIMAL(38,18) NOT NULL"},
+	{"trades", "price_scale", "TINYINT NOT NULL DEFAULT 0"},
+	{"trades", "quantity_scale", "TINYINT NOT NULL DEFAULT 0"},
+	{"trades", "venue", "ENUM('lit', 'dark') NOT NULL DEFAULT 'lit'"},
+	{"order_groups", "type", "ENUM('oco', 'bracket') NOT NULL"},
+	{"order_groups", "take_profit_price", "DECIMAL(38,18)"},
+	{"order_groups", "stop_loss_price", "DECIMAL(38,18)"},
+	{"order_groups", "stop_loss_limit_price", "DECIMAL(38,18)"},
+	{"order_groups", "price_scale", "TINYINT NOT NULL DEFAULT 0"},
+	{"instruments", "tick_size", "DECIMAL(38,18) NOT NULL DEFAULT 1"},
+	{"instruments", "lot_size", "DECIMAL(38,18) NOT NULL DEFAULT 1"},
+	{"instruments", "min_quantity", "DECIMAL(38,18) NOT NULL DEFAULT 1"},
+	{"instruments", "max_quantity", "DECIMAL(38,18) NOT NULL DEFAULT 0"},
+	{"instruments", "quantity_scale", "TINYINT NOT NULL DEFAULT 0"},
+	{"trading_phases", "phase", "ENUM('continuous', 'auction', 'halted', 'closed', 'batch') NOT NULL"},
+	{"trading_phases", "reason", "VARCHAR(255) NOT NULL DEFAULT ''"},
+	{"trading_phases", "phase_until", "TIMESTAMP NULL"},
+	// matching mode set by an admin, the engine's configured mode applies without one
+	{"trading_phases", "mode", "ENUM('continuous', 'batch')"},
+}
+
+// syncColumn adds a missing column and changes an ENUM or DECIMAL column whose values or
+// precision changed
+func syncColumn(db *sql.DB, c column) error {
+	var columnType string
+	err := db.QueryRow(
+		`SELECT COLUMN_TYPE FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?`, c.table, c.name).
+		Scan(&columnType)
+
+	switch {
+	case err == sql.ErrNoRows:
+		_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.name, c.definition))
+		return err
+	case err != nil:
+		return err
+	}
+
+	if !strings.HasPrefix(c.definition, "ENUM(") && !strings.HasPrefix(c.definition, "DECIMAL(") {
+		return nil
+	}
+
+	// information_schema reports enums as enum('a','b') and decimals as decimal(38,18)
+	definedType := c.definition[:strings.Index(c.definition, ")")+1]
+	if strings.EqualFold(columnType, strings.ReplaceAll(definedType, ", ", ",")) {
+		return nil
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s", c.table, c.name, c.definition))
+	return err
+}
+
+// columnExists reports whether a table has a column
+func columnExists(db *sql.DB, table, name string) (bool, error) {
+	var exists bool
+	err := db.QueryRow(
+		`SELECT COUNT(*) > 0 FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?`, table, name).
+		Scan(&exists)
+	return exists, err
+}
+
+// scalePrices turns the prices stored as units of their instrument's price scale into
+// decimal values, quantities were whole units and stay as they are
+func scalePrices(db *sql.DB) error {
+	// 10^price_scale is exact as a DOUBLE, the division keeps all 18 decimals
+	const unit = `CAST(POW(10, i.price_scale) AS DECIMAL(19,0))`
+
+	statements := []string{
+		`UPDATE orders o JOIN instruments i ON i.symbol = o.symbol
+		SET o.price_scale = i.price_scale, o.price = o.price / ` + unit + `, o.stop_price = o.stop_price / ` + unit + `,
+		o.protection_price = o.protection_price / ` + unit + `, o.trail_amount = o.trail_amount / ` + unit + `,
+		o.limit_offset = o.limit_offset / ` + unit + `
+		WHERE i.price_scale > 0`,
+		`UPDATE trades t JOIN instruments i ON i.symbol = t.symbol
+		SET t.price_scale = i.price_scale, t.price = t.price / ` + unit + `
+		WHERE i.price_scale > 0`,
+		`UPDATE order_groups g JOIN (SELECT DISTINCT group_id, symbol FROM orders WHERE group_id IS NOT NULL) o ON o.group_id = g.group_id
+		JOIN instruments i ON i.symbol = o.symbol
+		SET g.price_scale = i.price_scale, g.take_profit_price = g.take_profit_price / ` + unit + `,
+		g.stop_loss_price = g.stop_loss_price / ` + unit + `, g.stop_loss_limit_price = g.stop_loss_limit_price / ` + unit + `
+		WHERE i.price_scale > 0`,
+		`UPDATE instruments i SET i.tick_size = i.tick_size / ` + unit + ` WHERE i.price_scale > 0`,
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // implement the storage.Storage interface
 func (m *Mysql) GetAllOrders() ([]*types.Order, error) {
-	rows, err := m.DB.Query(`SELECT
-	order_id, symbol, side, type, price, quantity, remaining, status, created_at, updated_at
-	FROM orders ORDER BY created_at DESC`)
+	rows, err := m.DB.Query(`SELECT ` + orderColumns + ` FROM orders ORDER BY created_at DESC`)
 	if err != nil {
 		return nil, err
 	}
@@ -96,12 +447,11 @@ func (m *Mysql) GetAllOrders() ([]*types.Order, error) {
 
 	var orders []*types.Order
 	for rows.Next() {
-		var order types.Order
-		err := rows.Scan(&order.OrderID, &order.Symbol, &order.Side, &order.OrderType, &order.Price, &order.Quantity, &order.Remaining, &order.Status, &order.CreatedAt, &order.UpdatedAt)
+		order, err := scanOrder(rows)
 		if err != nil {
 			return nil, err
 		}
-		orders = append(orders, &order)
+		orders = append(orders, order)
 	}
 
 	if err = rows.Err(); err != nil {
@@ -126,8 +476,8 @@ func (m *Mysql) PlaceOrder(tx storage.Tx, order types.Order) (int64, error) {
 
 	if tx != nil {
 		txImpl := tx.(*mysqlTx)
-		stmt, err = txImpl.tx.Prepare(`INSERT INTO orders (symbol, side, type, price, quantity, remaining, status, created_at, updated_at) 
-		VALUES (?, ?, ?, ?, ?, ?, ?, NOW(), NOW())`)
+		stmt, err = txImpl.tx.Prepare(`INSERT INTO orders (symbol, account_id, side, type, price, quantity, remaining, status, time_in_force, expire_at, stop_price, display_quantity, visible_remaining, post_only, stp_mode, max_slippage_bps, protection_price, trail_amount, trail_percent, limit_offset, group_id, reason, quote_quantity, quote_remaining, min_quantity, all_or_none, hidden, dark, peg_type, peg_offset, peg_limit, price_scale, quantity_scale, created_at, updated_at) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())`)
 	} else {
 		return 0, fmt.Errorf("transaction is nil")
 	}
@@ -138,7 +488,15 @@ func (m *Mysql) PlaceOrder(tx storage.Tx, order types.Order) (int64, error) {
 
 	defer stmt.Close()
 
-	result, err := stmt.Exec(order.Symbol, order.Side, order.OrderType, order.Price, order.Quantity, order.Remaining, order.Status)
+	price, quantity := order.PriceScale, order.QuantityScale
+	result, err := stmt.Exec(order.Symbol, order.AccountID, order.Side, order.OrderType, nullDecimalValue(order.Price, price),
+		decimalValue(order.Quantity, quantity), decimalValue(order.Remaining, quantity), order.Status, order.TimeInForce, order.ExpireAt,
+		nullDecimalValue(order.StopPrice, price), decimalValue(order.DisplayQuantity, quantity), decimalValue(order.VisibleRemaining, quantity),
+		order.PostOnly, order.STPMode, order.MaxSlippageBps, nullDecimalValue(order.ProtectionPrice, price), decimalValue(order.TrailAmount, price),
+		decimalValue(order.TrailPercentBps, 2), decimalValue(order.LimitOffset, price), order.GroupID, order.Reason,
+		decimalValue(order.QuoteQuantity, price), decimalValue(order.QuoteRemaining, price),
+		decimalValue(order.MinQuantity, quantity), order.AllOrNone, order.Hidden, order.Dark,
+		sql.NullString{String: string(order.PegType), Valid: order.IsPegged()}, decimalValue(order.PegOffset, price), nullDecimalValue(order.PegLimit, price), price, quantity)
 	if err != nil {
 		return 0, err
 	}
@@ -152,15 +510,30 @@ func (m *Mysql) PlaceOrder(tx storage.Tx, order types.Order) (int64, error) {
 }
 
 func (m *Mysql) GetMatchingOrders(symbol string, side *types.OrderSide) ([]*types.Order, error) {
+	return m.getMatchingOrders(m.DB, symbol, side, false)
+}
+
+// GetMatchingOrdersTx reads the open orders within the transaction and locks them until it ends
+func (m *Mysql) GetMatchingOrdersTx(tx storage.Tx, symbol string, side *types.OrderSide) ([]*types.Order, error) {
+	if tx == nil {
+		return nil, fmt.Errorf("transaction is nil")
+	}
+
+	txImpl := tx.(*mysqlTx)
+	orders, err := m.getMatchingOrders(txImpl.tx, symbol, side, true)
+	return orders, conflictError(err)
+}
+
+func (m *Mysql) getMatchingOrders(q queryer, symbol string, side *types.OrderSide, forUpdate bool) ([]*types.Order, error) {
 	var (
 		query  string
 		params []interface{}
 	)
 
 	// Base query
-	query = `SELECT order_id, symbol, side, type, price, quantity, remaining, status, created_at, updated_at
+	query = `SELECT ` + orderColumns + `
 			 FROM orders 
-			 WHERE symbol = ? AND status IN ('open', 'partial')`
+			 WHERE symbol = ? AND status IN ('open', 'partial', 'triggered')`
 	params = append(params, symbol)
 
 	// Add side condition if provided
@@ -171,16 +544,20 @@ func (m *Mysql) GetMatchingOrders(symbol string, side *types.OrderSide) ([]*type
 		// Set order by based on side
 		switch *side {
 		case types.BUY:
-			query += " ORDER BY price DESC, created_at ASC"
+			query += " ORDER BY price DESC, priority_at ASC, order_id ASC"
 		case types.SELL:
-			query += " ORDER BY price ASC, created_at ASC"
+			query += " ORDER BY price ASC, priority_at ASC, order_id ASC"
 		}
 	} else {
-		// Default for orderbook
-		query += " ORDER BY created_at ASC"
+		// Default for orderbook, time priority
+		query += " ORDER BY priority_at ASC, order_id ASC"
 	}
 
-	rows, err := m.DB.Query(query, params...)
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	rows, err := q.Query(query, params...)
 	if err != nil {
 		return nil, err
 	}
@@ -188,12 +565,11 @@ func (m *Mysql) GetMatchingOrders(symbol string, side *types.OrderSide) ([]*type
 
 	var orders []*types.Order
 	for rows.Next() {
-		var order types.Order
-		err := rows.Scan(&order.OrderID, &order.Symbol, &order.Side, &order.OrderType, &order.Price, &order.Quantity, &order.Remaining, &order.Status, &order.CreatedAt, &order.UpdatedAt)
+		order, err := scanOrder(rows)
 		if err != nil {
 			return nil, err
 		}
-		orders = append(orders, &order)
+		orders = append(orders, order)
 	}
 
 	if err = rows.Err(); err != nil {
@@ -203,15 +579,15 @@ func (m *Mysql) GetMatchingOrders(symbol string, side *types.OrderSide) ([]*type
 	return orders, nil
 }
 
-func (m *Mysql) UpdateOrder(tx storage.Tx, orderID int64, remaining int64, status types.OrderStatus) error {
+func (m *Mysql) UpdateOrder(tx storage.Tx, order types.Order) error {
 	var stmt *sql.Stmt
 	var err error
 
 	if tx != nil {
 		txImpl := tx.(*mysqlTx)
-		stmt, err = txImpl.tx.Prepare(`UPDATE orders SET remaining = ?, status = ?, updated_at = NOW() WHERE order_id = ?`)
+		stmt, err = txImpl.tx.Prepare(`UPDATE orders SET remaining = ?, quote_remaining = ?, status = ?, updated_at = NOW() WHERE order_id = ?`)
 	} else {
-		stmt, err = m.DB.Prepare(`UPDATE orders SET remaining = ?, status = ?, updated_at = NOW() WHERE order_id = ?`)
+		stmt, err = m.DB.Prepare(`UPDATE orders SET remaining = ?, quote_remaining = ?, status = ?, updated_at = NOW() WHERE order_id = ?`)
 	}
 
 	if err != nil {
@@ -220,7 +596,7 @@ func (m *Mysql) UpdateOrder(tx storage.Tx, orderID int64, remaining int64, statu
 
 	defer stmt.Close()
 
-	result, err := stmt.Exec(remaining, status, orderID)
+	result, err := stmt.Exec(decimalValue(order.Remaining, order.QuantityScale), decimalValue(order.QuoteRemaining, order.PriceScale), order.Status, order.OrderID)
 	if err != nil {
 		return err
 	}
@@ -237,13 +613,75 @@ func (m *Mysql) UpdateOrder(tx storage.Tx, orderID int64, remaining int64, statu
 	return nil
 }
 
-func (m *Mysql) MarkOrderCancelled(tx storage.Tx, orderID int64) error {
+// UpdateOrderVisible stores what is left of the shown slice of an iceberg order, requeue
+// moves the order to the back of its price level
+func (m *Mysql) UpdateOrderVisible(tx storage.Tx, order types.Order, requeue bool) error {
+	if tx == nil {
+		return fmt.Errorf("transaction is nil")
+	}
+
+	query := `UPDATE orders SET visible_remaining = ?, updated_at = NOW() WHERE order_id = ?`
+	if requeue {
+		query = `UPDATE orders SET visible_remaining = ?, priority_at = NOW(6), updated_at = NOW() WHERE order_id = ?`
+	}
+
+	txImpl := tx.(*mysqlTx)
+	_, err := txImpl.tx.Exec(query, decimalValue(order.VisibleRemaining, order.QuantityScale), order.OrderID)
+	return err
+}
+
+// AmendOrder stores the price, quantities and status of an amended working order,
+// requeue moves the order to the back of its price level
+func (m *Mysql) AmendOrder(tx storage.Tx, order types.Order, requeue bool) error {
+	if tx == nil {
+		return fmt.Errorf("transaction is nil")
+	}
+
+	query := `UPDATE orders SET price = ?, quantity = ?, remaining = ?, visible_remaining = ?, status = ?, updated_at = NOW()`
+	if requeue {
+		query += `, priority_at = NOW(6)`
+	}
+	query += ` WHERE order_id = ? AND status IN ('open', 'partial', 'triggered')`
+
+	txImpl := tx.(*mysqlTx)
+	result, err := txImpl.tx.Exec(query, nullDecimalValue(order.Price, order.PriceScale), decimalValue(order.Quantity, order.QuantityScale),
+		decimalValue(order.Remaining, order.QuantityScale), decimalValue(order.VisibleRemaining, order.QuantityScale), order.Status, order.OrderID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("order not found or no longer open")
+	}
+
+	return nil
+}
+
+// UpdateStopPrice stores the moved stop price of a pending trailing stop
+func (m *Mysql) UpdateStopPrice(tx storage.Tx, order types.Order) error {
+	if tx == nil {
+		return fmt.Errorf("transaction is nil")
+	}
+
+	txImpl := tx.(*mysqlTx)
+	_, err := txImpl.tx.Exec(`UPDATE orders SET stop_price = ?, updated_at = NOW() WHERE order_id = ?`,
+		nullDecimalValue(order.StopPrice, order.PriceScale), order.OrderID)
+	return err
+}
+
+// MarkOrderCancelled cancels a working order, reason is empty when the owner cancelled it
+func (m *Mysql) MarkOrderCancelled(tx storage.Tx, orderID int64, reason string) error {
 	var stmt *sql.Stmt
 	var err error
 
 	if tx != nil {
 		txImpl := tx.(*mysqlTx)
-		stmt, err = txImpl.tx.Prepare(`UPDATE orders SET status = 'cancelled' WHERE order_id = ? AND status IN ('open', 'partial')`)
+		stmt, err = txImpl.tx.Prepare(`UPDATE orders SET status = 'cancelled', reason = ?, updated_at = NOW() WHERE order_id = ? AND status IN ('open', 'partial', 'triggered')`)
 	} else {
 		return fmt.Errorf("transaction is nil")
 	}
@@ -254,7 +692,7 @@ func (m *Mysql) MarkOrderCancelled(tx storage.Tx, orderID int64) error {
 
 	defer stmt.Close()
 
-	result, err := stmt.Exec(orderID)
+	result, err := stmt.Exec(reason, orderID)
 	if err != nil {
 		return err
 	}
@@ -272,20 +710,115 @@ func (m *Mysql) MarkOrderCancelled(tx storage.Tx, orderID int64) error {
 }
 
 func (m *Mysql) GetOrderStatus(order_id int64) (*types.Order, error) {
-	var order types.Order
-	err := m.DB.QueryRow(
-		`SELECT order_id, symbol, side, type, price, quantity, remaining, status, created_at, updated_at 
-		FROM orders WHERE order_id = ?`, order_id).
-		Scan(&order.OrderID, &order.Symbol, &order.Side, &order.OrderType, &order.Price, &order.Quantity, &order.Remaining, &order.Status, &order.CreatedAt, &order.UpdatedAt)
+	return m.getOrder(m.DB, order_id, false)
+}
+
+// GetOrderStatusTx reads an order within the transaction and locks it until it ends
+func (m *Mysql) GetOrderStatusTx(tx storage.Tx, orderID int64) (*types.Order, error) {
+	if tx == nil {
+		return nil, fmt.Errorf("transaction is nil")
+	}
+
+	txImpl := tx.(*mysqlTx)
+	order, err := m.getOrder(txImpl.tx, orderID, true)
+	return order, conflictError(err)
+}
 
+func (m *Mysql) getOrder(q queryer, orderID int64, forUpdate bool) (*types.Order, error) {
+	query := `SELECT ` + orderColumns + ` FROM orders WHERE order_id = ?`
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	order, err := scanOrder(q.QueryRow(query, orderID))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("order not found")
+			return nil, storage.ErrOrderNotFound
 		}
 		return nil, err
 	}
 
-	return &order, nil
+	return order, nil
+}
+
+func (m *Mysql) CreateOrderGroup(tx storage.Tx, group types.OrderGroup) (int64, error) {
+	if tx == nil {
+		return 0, fmt.Errorf("transaction is nil")
+	}
+
+	txImpl := tx.(*mysqlTx)
+	result, err := txImpl.tx.Exec(`INSERT INTO order_groups (type, on_fill, take_profit_price, stop_loss_price, stop_loss_limit_price, price_scale, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, NOW())`, group.Type, group.OnFill, nullDecimalValue(group.TakeProfitPrice, group.PriceScale),
+		nullDecimalValue(group.StopLossPrice, group.PriceScale), nullDecimalValue(group.StopLossLimitPrice, group.PriceScale), group.PriceScale)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
+
+func (m *Mysql) GetOrderGroup(groupID int64) (*types.OrderGroup, error) {
+	return m.getOrderGroup(m.DB, groupID, false)
+}
+
+// GetOrderGroupTx reads an order group within the transaction and locks its orders until it ends
+func (m *Mysql) GetOrderGroupTx(tx storage.Tx, groupID int64) (*types.OrderGroup, error) {
+	if tx == nil {
+		return nil, fmt.Errorf("transaction is nil")
+	}
+
+	txImpl := tx.(*mysqlTx)
+	group, err := m.getOrderGroup(txImpl.tx, groupID, true)
+	return group, conflictError(err)
+}
+
+func (m *Mysql) getOrderGroup(q queryer, groupID int64, forUpdate bool) (*types.OrderGroup, error) {
+	var (
+		group                                              types.OrderGroup
+		takeProfitPrice, stopLossPrice, stopLossLimitPrice sql.NullString
+	)
+	err := q.QueryRow(`SELECT group_id, type, on_fill, take_profit_price, stop_loss_price, stop_loss_limit_price, price_scale, created_at
+		FROM order_groups WHERE group_id = ?`, groupID).
+		Scan(&group.GroupID, &group.Type, &group.OnFill, &takeProfitPrice, &stopLossPrice, &stopLossLimitPrice, &group.PriceScale, &group.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.ErrGroupNotFound
+		}
+		return nil, err
+	}
+
+	var units unitsReader
+	group.TakeProfitPrice = units.nullable(takeProfitPrice, group.PriceScale)
+	group.StopLossPrice = units.nullable(stopLossPrice, group.PriceScale)
+	group.StopLossLimitPrice = units.nullable(stopLossLimitPrice, group.PriceScale)
+	if units.err != nil {
+		return nil, fmt.Errorf("order group %d: %w", group.GroupID, units.err)
+	}
+
+	query := `SELECT ` + orderColumns + ` FROM orders WHERE group_id = ? ORDER BY order_id ASC`
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	rows, err := q.Query(query, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		group.Orders = append(group.Orders, order)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &group, nil
 }
 
 func (m *Mysql) CreateTrade(tx storage.Tx, trade types.Trade) (int64, error) {
@@ -294,7 +827,8 @@ func (m *Mysql) CreateTrade(tx storage.Tx, trade types.Trade) (int64, error) {
 
 	if tx != nil {
 		txImpl := tx.(*mysqlTx)
-		stmt, err = txImpl.tx.Prepare(`INSERT INTO trades (symbol, buy_order_id, sell_order_id, price, quantity, created_at, updated_at) VALUES (?, ?, ?, ?, ?, NOW(), NOW())`)
+		stmt, err = txImpl.tx.Prepare(`INSERT INTO trades (symbol, buy_order_id, sell_order_id, price, quantity, price_scale, quantity_scale, venue, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())`)
 	} else {
 		return 0, fmt.Errorf("transaction is nil")
 	}
@@ -305,7 +839,8 @@ func (m *Mysql) CreateTrade(tx storage.Tx, trade types.Trade) (int64, error) {
 
 	defer stmt.Close()
 
-	result, err := stmt.Exec(trade.Symbol, trade.BuyOrderID, trade.SellOrderID, trade.Price, trade.Quantity)
+	result, err := stmt.Exec(trade.Symbol, trade.BuyOrderID, trade.SellOrderID, decimalValue(trade.Price, trade.PriceScale),
+		decimalValue(trade.Quantity, trade.QuantityScale), trade.PriceScale, trade.QuantityScale, trade.Venue)
 	if err != nil {
 		return 0, err
 	}
@@ -318,9 +853,179 @@ func (m *Mysql) CreateTrade(tx storage.Tx, trade types.Trade) (int64, error) {
 	return tradeID, nil
 }
 
+// instrumentColumns lists the columns read into a types.Instrument, in scanInstrument order
+const instrumentColumns = `symbol, base_asset, quote_asset, tick_size, lot_size, min_quantity, max_quantity, price_scale, quantity_scale, status, created_at, updated_at`
+
+func scanInstrument(row scanner) (*types.Instrument, error) {
+	var (
+		instrument                                  types.Instrument
+		tickSize, lotSize, minQuantity, maxQuantity string
+	)
+	err := row.Scan(&instrument.Symbol, &instrument.BaseAsset, &instrument.QuoteAsset, &tickSize, &lotSize, &minQuantity, &maxQuantity,
+		&instrument.PriceScale, &instrument.QuantityScale, &instrument.Status, &instrument.CreatedAt, &instrument.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	var units unitsReader
+	instrument.TickSize = units.read(tickSize, instrument.PriceScale)
+	instrument.LotSize = units.read(lotSize, instrument.QuantityScale)
+	instrument.MinQuantity = units.read(minQuantity, instrument.QuantityScale)
+	instrument.MaxQuantity = units.read(maxQuantity, instrument.QuantityScale)
+	if units.err != nil {
+		return nil, fmt.Errorf("instrument %s: %w", instrument.Symbol, units.err)
+	}
+	return &instrument, nil
+}
+
+func (m *Mysql) CreateInstrument(instrument types.Instrument) error {
+	price, quantity := instrument.PriceScale, instrument.QuantityScale
+	_, err := m.DB.Exec(`INSERT INTO instruments (symbol, base_asset, quote_asset, tick_size, lot_size, min_quantity, max_quantity, price_scale, quantity_scale, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())`,
+		instrument.Symbol, instrument.BaseAsset, instrument.QuoteAsset, decimalValue(instrument.TickSize, price), decimalValue(instrument.LotSize, quantity),
+		decimalValue(instrument.MinQuantity, quantity), decimalValue(instrument.MaxQuantity, quantity), price, quantity, instrument.Status)
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == errCodeDuplicateEntry {
+		return storage.ErrInstrumentExists
+	}
+	return err
+}
+
+func (m *Mysql) GetInstrument(symbol string) (*types.Instrument, error) {
+	instrument, err := scanInstrument(m.DB.QueryRow(`SELECT `+instrumentColumns+` FROM instruments WHERE symbol = ?`, symbol))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.ErrInstrumentNotFound
+		}
+		return nil, err
+	}
+
+	return instrument, nil
+}
+
+func (m *Mysql) ListInstruments() ([]types.Instrument, error) {
+	rows, err := m.DB.Query(`SELECT ` + instrumentColumns + ` FROM instruments ORDER BY symbol ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	instruments := []types.Instrument{}
+	for rows.Next() {
+		instrument, err := scanInstrument(rows)
+		if err != nil {
+			return nil, err
+		}
+		instruments = append(instruments, *instrument)
+	}
+
+	return instruments, rows.Err()
+}
+
+func (m *Mysql) UpdateInstrument(instrument types.Instrument) error {
+	// the scales stay, the stored prices and quantities are written in them
+	price, quantity := instrument.PriceScale, instrument.QuantityScale
+	_, err := m.DB.Exec(`UPDATE instruments SET base_asset = ?, quote_asset = ?, tick_size = ?, lot_size = ?, min_quantity = ?,
+		max_quantity = ?, status = ?, updated_at = NOW() WHERE symbol = ?`,
+		instrument.BaseAsset, instrument.QuoteAsset, decimalValue(instrument.TickSize, price), decimalValue(instrument.LotSize, quantity),
+		decimalValue(instrument.MinQuantity, quantity), decimalValue(instrument.MaxQuantity, quantity), instrument.Status, instrument.Symbol)
+	return err
+}
+
+func (m *Mysql) DeleteInstrument(symbol string) error {
+	result, err := m.DB.Exec(`DELETE FROM instruments WHERE symbol = ?`, symbol)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return storage.ErrInstrumentNotFound
+	}
+
+	return nil
+}
+
+// GetSymbolStatus returns the trading phase of a symbol, symbols trade continuously until set otherwise.
+// The mode is empty unless an admin set one.
+func (m *Mysql) GetSymbolStatus(symbol string) (*types.SymbolStatus, error) {
+	var mode sql.NullString
+	status := types.SymbolStatus{Symbol: symbol}
+	err := m.DB.QueryRow(`SELECT phase, reason, phase_until, mode, updated_at FROM trading_phases WHERE symbol = ?`, symbol).
+		Scan(&status.Phase, &status.Reason, &status.Until, &mode, &status.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			status.Phase = types.CONTINUOUS
+			return &status, nil
+		}
+		return nil, err
+	}
+	status.Mode = types.TradingPhase(mode.String)
+
+	return &status, nil
+}
+
+func (m *Mysql) SetSymbolStatus(tx storage.Tx, status types.SymbolStatus) error {
+	if tx == nil {
+		return fmt.Errorf("transaction is nil")
+	}
+
+	txImpl := tx.(*mysqlTx)
+	_, err := txImpl.tx.Exec(`INSERT INTO trading_phases (symbol, phase, reason, phase_until, updated_at) VALUES (?, ?, ?, ?, NOW())
+		ON DUPLICATE KEY UPDATE phase = VALUES(phase), reason = VALUES(reason), phase_until = VALUES(phase_until), updated_at = NOW()`,
+		status.Symbol, status.Phase, status.Reason, status.Until)
+	return conflictError(err)
+}
+
+// SetMatchingMode stores the matching mode an admin chose for a symbol, its phase stays
+func (m *Mysql) SetMatchingMode(tx storage.Tx, symbol string, mode types.TradingPhase) error {
+	if tx == nil {
+		return fmt.Errorf("transaction is nil")
+	}
+
+	txImpl := tx.(*mysqlTx)
+	_, err := txImpl.tx.Exec(`INSERT INTO trading_phases (symbol, phase, mode, updated_at) VALUES (?, ?, ?, NOW())
+		ON DUPLICATE KEY UPDATE mode = VALUES(mode), updated_at = NOW()`,
+		symbol, types.CONTINUOUS, mode)
+	return conflictError(err)
+}
+
+// GetLastTradePrice returns the price of the latest lit trade of a symbol, nil when it never
+// traded. Dark trades print at the lit midpoint and don't set the price.
+func (m *Mysql) GetLastTradePrice(symbol string) (*int64, error) {
+	return lastTradePrice(m.DB.QueryRow(`SELECT price, price_scale FROM trades WHERE symbol = ? AND venue = 'lit' ORDER BY trade_id DESC LIMIT 1`, symbol))
+}
+
+// GetLastTradePriceBefore returns the price of the symbol's last lit trade before a time, nil when there was none
+func (m *Mysql) GetLastTradePriceBefore(symbol string, before time.Time) (*int64, error) {
+	return lastTradePrice(m.DB.QueryRow(`SELECT price, price_scale FROM trades WHERE symbol = ? AND venue = 'lit' AND created_at < ? ORDER BY trade_id DESC LIMIT 1`, symbol, before))
+}
+
+// lastTradePrice reads the price of a trade row, nil when there is none
+func lastTradePrice(row *sql.Row) (*int64, error) {
+	var (
+		value string
+		scale int
+	)
+	if err := row.Scan(&value, &scale); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var units unitsReader
+	price := units.read(value, scale)
+	return &price, units.err
+}
+
 func (m *Mysql) ListTrades(symbol string) ([]types.Trade, error) {
 	query := `
-        SELECT t.trade_id, t.symbol, t.buy_order_id, t.sell_order_id, t.price, t.quantity, t.created_at, t.updated_at
+        SELECT t.trade_id, t.symbol, t.buy_order_id, t.sell_order_id, t.price, t.quantity, t.price_scale, t.quantity_scale, t.venue, t.created_at, t.updated_at
         FROM trades t
         WHERE t.symbol = ?
         ORDER BY t.created_at DESC
@@ -334,20 +1039,33 @@ func (m *Mysql) ListTrades(symbol string) ([]types.Trade, error) {
 
 	var trades []types.Trade
 	for rows.Next() {
-		var trade types.Trade
+		var (
+			trade           types.Trade
+			price, quantity string
+		)
 		err := rows.Scan(
 			&trade.TradeID,
 			&trade.Symbol,
 			&trade.BuyOrderID,
 			&trade.SellOrderID,
-			&trade.Price,
-			&trade.Quantity,
+			&price,
+			&quantity,
+			&trade.PriceScale,
+			&trade.QuantityScale,
+			&trade.Venue,
 			&trade.CreatedAt,
 			&trade.UpdatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("scan error: %w", err)
 		}
+
+		var units unitsReader
+		trade.Price = units.read(price, trade.PriceScale)
+		trade.Quantity = units.read(quantity, trade.QuantityScale)
+		if units.err != nil {
+			return nil, fmt.Errorf("trade %d: %w", trade.TradeID, units.err)
+		}
 		trades = append(trades, trade)
 	}
 
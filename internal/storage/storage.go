@@ -1,6 +1,24 @@
 package storage
 
-import "github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+import (
+	"errors"
+	"time"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/types"
+)
+
+var (
+	// ErrOrderNotFound is returned when no order exists with the requested id
+	ErrOrderNotFound = errors.New("order not found")
+	// ErrGroupNotFound is returned when no order group exists with the requested id
+	ErrGroupNotFound = errors.New("order group not found")
+	// ErrInstrumentNotFound is returned when no instrument is listed under the requested symbol
+	ErrInstrumentNotFound = errors.New("instrument not found")
+	// ErrInstrumentExists is returned when an instrument is listed under the symbol already
+	ErrInstrumentExists = errors.New("instrument already exists")
+	// ErrWriteConflict is returned when a transaction lost against a concurrent one and can be retried
+	ErrWriteConflict = errors.New("write conflict")
+)
 
 // Tx represents a database transaction
 type Tx interface {
@@ -13,12 +31,38 @@ type Storage interface {
 	Begin() (Tx, error)
 
 	PlaceOrder(tx Tx, order types.Order) (int64, error)
-	UpdateOrder(tx Tx, orderID int64, remaining int64, status types.OrderStatus) error
-	MarkOrderCancelled(tx Tx, orderID int64) error
+	UpdateOrder(tx Tx, order types.Order) error
+	UpdateOrderVisible(tx Tx, order types.Order, requeue bool) error
+	AmendOrder(tx Tx, order types.Order, requeue bool) error
+	UpdateStopPrice(tx Tx, order types.Order) error
+	MarkOrderCancelled(tx Tx, orderID int64, reason string) error
 	GetOrderStatus(orderID int64) (*types.Order, error)
 	GetMatchingOrders(symbol string, side *types.OrderSide) ([]*types.Order, error)
 	GetAllOrders() ([]*types.Order, error)
 
+	// Locking reads, the returned rows stay locked until the transaction ends
+	GetOrderStatusTx(tx Tx, orderID int64) (*types.Order, error)
+	GetMatchingOrdersTx(tx Tx, symbol string, side *types.OrderSide) ([]*types.Order, error)
+
+	// Order groups, GetOrderGroupTx locks the group's orders
+	CreateOrderGroup(tx Tx, group types.OrderGroup) (int64, error)
+	GetOrderGroup(groupID int64) (*types.OrderGroup, error)
+	GetOrderGroupTx(tx Tx, groupID int64) (*types.OrderGroup, error)
+
+	// Instrument registry, orders are only taken for listed symbols
+	CreateInstrument(instrument types.Instrument) error
+	GetInstrument(symbol string) (*types.Instrument, error)
+	ListInstruments() ([]types.Instrument, error)
+	UpdateInstrument(instrument types.Instrument) error
+	DeleteInstrument(symbol string) error
+
+	// Trading phase and matching mode of a symbol, SetSymbolStatus keeps the mode
+	GetSymbolStatus(symbol string) (*types.SymbolStatus, error)
+	SetSymbolStatus(tx Tx, status types.SymbolStatus) error
+	SetMatchingMode(tx Tx, symbol string, mode types.TradingPhase) error
+
 	CreateTrade(tx Tx, trade types.Trade) (int64, error)
 	ListTrades(symbol string) ([]types.Trade, error)
+	GetLastTradePrice(symbol string) (*int64, error)
+	GetLastTradePriceBefore(symbol string, before time.Time) (*int64, error)
 }
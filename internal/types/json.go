@@ -0,0 +1,130 @@
+package types
+
+import (
+	"encoding/json"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/decimal"
+)
+
+// The engine keeps prices and quantities as units of their scale, the API writes them
+// as decimal strings. Each type is marshalled through a copy without its MarshalJSON
+// method, the decimal fields shadow the unit fields of the same name.
+
+func (o Order) MarshalJSON() ([]byte, error) {
+	type order Order
+	return json.Marshal(struct {
+		order
+		Price            *decimal.Decimal `json:"price,omitempty"`
+		StopPrice        *decimal.Decimal `json:"stop_price,omitempty"`
+		Quantity         decimal.Decimal  `json:"quantity"`
+		Remaining        decimal.Decimal  `json:"remaining"`
+		DisplayQuantity  *decimal.Decimal `json:"display_quantity,omitempty"`
+		VisibleRemaining *decimal.Decimal `json:"visible_remaining,omitempty"`
+		ProtectionPrice  *decimal.Decimal `json:"protection_price,omitempty"`
+		TrailAmount      *decimal.Decimal `json:"trail_amount,omitempty"`
+		TrailPercent     *decimal.Decimal `json:"trail_percent,omitempty"`
+		LimitOffset      *decimal.Decimal `json:"limit_offset,omitempty"`
+		QuoteQuantity    *decimal.Decimal `json:"quote_quantity,omitempty"`
+		QuoteRemaining   *decimal.Decimal `json:"quote_remaining,omitempty"`
+		MinQuantity      *decimal.Decimal `json:"min_quantity,omitempty"`
+		PegOffset        *decimal.Decimal `json:"peg_offset,omitempty"`
+		PegLimit         *decimal.Decimal `json:"peg_limit,omitempty"`
+	}{
+		order:            order(o),
+		Price:            decimal.NewPtr(o.Price, o.PriceScale),
+		StopPrice:        decimal.NewPtr(o.StopPrice, o.PriceScale),
+		Quantity:         decimal.New(o.Quantity, o.QuantityScale),
+		Remaining:        decimal.New(o.Remaining, o.QuantityScale),
+		DisplayQuantity:  nonZero(o.DisplayQuantity, o.QuantityScale),
+		VisibleRemaining: nonZero(o.VisibleRemaining, o.QuantityScale),
+		ProtectionPrice:  decimal.NewPtr(o.ProtectionPrice, o.PriceScale),
+		TrailAmount:      nonZero(o.TrailAmount, o.PriceScale),
+		TrailPercent:     nonZero(o.TrailPercentBps, 2),
+		LimitOffset:      nonZero(o.LimitOffset, o.PriceScale),
+		QuoteQuantity:    nonZero(o.QuoteQuantity, o.PriceScale),
+		QuoteRemaining:   nonZero(o.QuoteRemaining, o.PriceScale),
+		MinQuantity:      nonZero(o.MinQuantity, o.QuantityScale),
+		PegOffset:        nonZero(o.PegOffset, o.PriceScale),
+		PegLimit:         decimal.NewPtr(o.PegLimit, o.PriceScale),
+	})
+}
+
+func (t Trade) MarshalJSON() ([]byte, error) {
+	type trade Trade
+	return json.Marshal(struct {
+		trade
+		Quantity decimal.Decimal `json:"quantity"`
+		Price    decimal.Decimal `json:"price"`
+	}{
+		trade:    trade(t),
+		Quantity: decimal.New(t.Quantity, t.QuantityScale),
+		Price:    decimal.New(t.Price, t.PriceScale),
+	})
+}
+
+func (s SelfTrade) MarshalJSON() ([]byte, error) {
+	type selfTrade SelfTrade
+	return json.Marshal(struct {
+		selfTrade
+		Quantity *decimal.Decimal `json:"quantity,omitempty"`
+	}{
+		selfTrade: selfTrade(s),
+		Quantity:  nonZero(s.Quantity, s.QuantityScale),
+	})
+}
+
+func (g OrderGroup) MarshalJSON() ([]byte, error) {
+	type orderGroup OrderGroup
+	return json.Marshal(struct {
+		orderGroup
+		TakeProfitPrice    *decimal.Decimal `json:"take_profit_price,omitempty"`
+		StopLossPrice      *decimal.Decimal `json:"stop_loss_price,omitempty"`
+		StopLossLimitPrice *decimal.Decimal `json:"stop_loss_limit_price,omitempty"`
+	}{
+		orderGroup:         orderGroup(g),
+		TakeProfitPrice:    decimal.NewPtr(g.TakeProfitPrice, g.PriceScale),
+		StopLossPrice:      decimal.NewPtr(g.StopLossPrice, g.PriceScale),
+		StopLossLimitPrice: decimal.NewPtr(g.StopLossLimitPrice, g.PriceScale),
+	})
+}
+
+func (a Auction) MarshalJSON() ([]byte, error) {
+	type auction Auction
+	return json.Marshal(struct {
+		auction
+		IndicativePrice  *decimal.Decimal `json:"indicative_price"`
+		IndicativeVolume decimal.Decimal  `json:"indicative_volume"`
+		Imbalance        decimal.Decimal  `json:"imbalance"`
+	}{
+		auction:          auction(a),
+		IndicativePrice:  decimal.NewPtr(a.IndicativePrice, a.PriceScale),
+		IndicativeVolume: decimal.New(a.IndicativeVolume, a.QuantityScale),
+		Imbalance:        decimal.New(a.Imbalance, a.QuantityScale),
+	})
+}
+
+func (i Instrument) MarshalJSON() ([]byte, error) {
+	type instrument Instrument
+	return json.Marshal(struct {
+		instrument
+		TickSize    decimal.Decimal  `json:"tick_size"`
+		LotSize     decimal.Decimal  `json:"lot_size"`
+		MinQuantity decimal.Decimal  `json:"min_quantity"`
+		MaxQuantity *decimal.Decimal `json:"max_quantity,omitempty"`
+	}{
+		instrument:  instrument(i),
+		TickSize:    decimal.New(i.TickSize, i.PriceScale),
+		LotSize:     decimal.New(i.LotSize, i.QuantityScale),
+		MinQuantity: decimal.New(i.MinQuantity, i.QuantityScale),
+		MaxQuantity: nonZero(i.MaxQuantity, i.QuantityScale),
+	})
+}
+
+// nonZero returns the decimal of units at scale, nil for 0 so omitempty leaves it out
+func nonZero(units int64, scale int) *decimal.Decimal {
+	if units == 0 {
+		return nil
+	}
+	d := decimal.New(units, scale)
+	return &d
+}
@@ -1,20 +1,57 @@
 package types
 
-import "time"
+import (
+	"time"
+
+	"github.com/abhishek622/GOLANG-ORDER-MATCHING-SYSTEM/internal/decimal"
+)
 
 type OrderSide string
 type OrderType string
 type OrderStatus string
+type TimeInForce string
+type STPMode string
+type GroupType string
+type GroupFillAction string
+type TradingPhase string
+type InstrumentStatus string
+type PegType string
+type Venue string
 
-// Order Types: Support both Limit Orders and Market Orders for Buy and Sell sides.
+// Order Types: Support Limit, Market, Stop and Stop Limit Orders for Buy and Sell sides.
 const (
 	BUY  OrderSide = "buy"
 	SELL OrderSide = "sell"
 )
 
+// Opposite returns the side an order of this side trades against
+func (s OrderSide) Opposite() OrderSide {
+	if s == BUY {
+		return SELL
+	}
+	return BUY
+}
+
 const (
 	LIMIT  OrderType = "limit"
 	MARKET OrderType = "market"
+	// stop orders wait in the trigger book until the last trade price reaches
+	// their stop price, then work as a market or limit order
+	STOP       OrderType = "stop"
+	STOP_LIMIT OrderType = "stop_limit"
+	// trailing stops move their stop price behind the best trade price seen since
+	// they were placed, by a fixed amount or a percentage
+	TRAILING_STOP       OrderType = "trailing_stop"
+	TRAILING_STOP_LIMIT OrderType = "trailing_stop_limit"
+	// pegged orders rest at a price that follows the top of the book, see PegType
+	PEGGED OrderType = "pegged"
+)
+
+// Peg types: the price a pegged order follows, its offset is added to it
+const (
+	PEG_PRIMARY  PegType = "primary"  // the best price of the order's own side
+	PEG_MARKET   PegType = "market"   // the best price of the opposite side
+	PEG_MIDPOINT PegType = "midpoint" // halfway between the best bid and the best ask
 )
 
 const (
@@ -22,47 +59,383 @@ const (
 	FILLED    OrderStatus = "filled"
 	PARTIAL   OrderStatus = "partial"
 	CANCELLED OrderStatus = "cancelled"
+	EXPIRED   OrderStatus = "expired"
+	TRIGGERED OrderStatus = "triggered" // a stop order that has been triggered and is working
+	REJECTED  OrderStatus = "rejected"  // refused by the engine without trading, see Reason
+)
+
+// Time in force: how long an order stays working before the remainder is cancelled
+const (
+	GTC TimeInForce = "gtc" // good till cancelled
+	IOC TimeInForce = "ioc" // immediate or cancel, the unfilled part is cancelled
+	FOK TimeInForce = "fok" // fill or kill, fully filled at once or not at all
+	DAY TimeInForce = "day" // expires at the end of the trading day
+	GTD TimeInForce = "gtd" // good till date, expires at expire_at
+)
+
+// Self-trade prevention: what happens when an order would trade against a resting
+// order of its own account, the incoming order's mode applies
+const (
+	CANCEL_NEWEST    STPMode = "cancel_newest"    // the incoming order is cancelled
+	CANCEL_OLDEST    STPMode = "cancel_oldest"    // the resting order is cancelled
+	CANCEL_BOTH      STPMode = "cancel_both"      // both orders are cancelled
+	DECREMENT_CANCEL STPMode = "decrement_cancel" // both shrink by the smaller quantity, the smaller is cancelled
+)
+
+// Order groups link orders so a fill on one acts on the others
+const (
+	OCO     GroupType = "oco"     // one cancels other
+	BRACKET GroupType = "bracket" // a parent order and the take-profit and stop-loss legs its fills open
+)
+
+// What a fill on one order of a group does to its siblings
+const (
+	FILL_CANCELS GroupFillAction = "cancel" // the siblings are cancelled
+	FILL_REDUCES GroupFillAction = "reduce" // the siblings shrink by the filled quantity
+)
+
+// Venues a trade can execute on
+const (
+	LIT  Venue = "lit"  // the order book
+	DARK Venue = "dark" // the dark book, at the lit midpoint
 )
 
+// Trading phases of a symbol
+const (
+	CONTINUOUS TradingPhase = "continuous" // orders match as they arrive
+	AUCTION    TradingPhase = "auction"    // orders accumulate and trade at a single price when the auction uncrosses
+	HALTED     TradingPhase = "halted"     // nothing trades, new orders are rejected or queue for the reopening
+	CLOSED     TradingPhase = "closed"     // nothing trades and no new orders are taken
+	BATCH      TradingPhase = "batch"      // orders accumulate and trade at a single price at the end of every batch
+)
+
+// Listing status of an instrument
+const (
+	ACTIVE   InstrumentStatus = "active"   // the instrument takes orders
+	INACTIVE InstrumentStatus = "inactive" // new orders are rejected, working orders can still be cancelled
+)
+
+// Order prices and quote amounts are counted in units of 10^-PriceScale and quantities
+// in units of 10^-QuantityScale, the scales of the order's instrument
 type Order struct {
-	OrderID   int64       `json:"order_id"`
-	Symbol    string      `json:"symbol"`
-	Side      OrderSide   `json:"side"`
-	OrderType OrderType   `json:"type"`
-	Price     *int64      `json:"price,omitempty"`
-	Quantity  int64       `json:"quantity"`
-	Remaining int64       `json:"remaining"`
-	Status    OrderStatus `json:"status"`
-	CreatedAt time.Time   `json:"created_at"`
-	UpdatedAt time.Time   `json:"updated_at"`
+	OrderID          int64       `json:"order_id"`
+	Symbol           string      `json:"symbol"`
+	AccountID        string      `json:"account_id"`
+	Side             OrderSide   `json:"side"`
+	OrderType        OrderType   `json:"type"`
+	Price            *int64      `json:"price,omitempty"`
+	StopPrice        *int64      `json:"stop_price,omitempty"`
+	Quantity         int64       `json:"quantity"`
+	Remaining        int64       `json:"remaining"`
+	DisplayQuantity  int64       `json:"display_quantity,omitempty"`
+	VisibleRemaining int64       `json:"visible_remaining,omitempty"`
+	PostOnly         bool        `json:"post_only,omitempty"`
+	Hidden           bool        `json:"hidden,omitempty"`
+	Dark             bool        `json:"dark,omitempty"`
+	MinQuantity      int64       `json:"min_quantity,omitempty"`
+	AllOrNone        bool        `json:"all_or_none,omitempty"`
+	STPMode          STPMode     `json:"stp_mode"`
+	MaxSlippageBps   int64       `json:"max_slippage_bps,omitempty"`
+	ProtectionPrice  *int64      `json:"protection_price,omitempty"`
+	TrailAmount      int64       `json:"trail_amount,omitempty"`
+	TrailPercentBps  int64       `json:"trail_percent,omitempty"`
+	LimitOffset      int64       `json:"limit_offset,omitempty"`
+	QuoteQuantity    int64       `json:"quote_quantity,omitempty"`
+	QuoteRemaining   int64       `json:"quote_remaining,omitempty"`
+	PegType          PegType     `json:"peg_type,omitempty"`
+	PegOffset        int64       `json:"peg_offset,omitempty"`
+	PegLimit         *int64      `json:"peg_limit,omitempty"`
+	PriceScale       int         `json:"-"`
+	QuantityScale    int         `json:"-"`
+	GroupID          *int64      `json:"group_id,omitempty"`
+	Status           OrderStatus `json:"status"`
+	Reason           string      `json:"reason,omitempty"`
+	TimeInForce      TimeInForce `json:"time_in_force"`
+	ExpireAt         *time.Time  `json:"expire_at,omitempty"`
+	CreatedAt        time.Time   `json:"created_at"`
+	UpdatedAt        time.Time   `json:"updated_at"`
+}
+
+// IsSelfTrade reports whether the order would trade against an order of its own account
+func (o *Order) IsSelfTrade(other *Order) bool {
+	return o.AccountID != "" && o.AccountID == other.AccountID
+}
+
+// HasQuoteQuantity reports whether the order spends an amount of the quote currency, its
+// quantity is what that amount buys
+func (o *Order) HasQuoteQuantity() bool {
+	return o.QuoteQuantity > 0
+}
+
+// IsMarket reports whether the order trades at any price once it is working
+func (o *Order) IsMarket() bool {
+	return o.OrderType == MARKET || o.OrderType == STOP || o.OrderType == TRAILING_STOP
+}
+
+// IsPegged reports whether the price of the order follows the top of the book
+func (o *Order) IsPegged() bool {
+	return o.OrderType == PEGGED
+}
+
+// IsStop reports whether the order has a stop price
+func (o *Order) IsStop() bool {
+	return o.OrderType == STOP || o.OrderType == STOP_LIMIT || o.IsTrailing()
+}
+
+// IsTrailing reports whether the order is a trailing stop
+func (o *Order) IsTrailing() bool {
+	return o.OrderType == TRAILING_STOP || o.OrderType == TRAILING_STOP_LIMIT
+}
+
+// Trail moves the stop price of a trailing stop after a trade at price. The stop only
+// ever moves towards the market, Trail reports whether it moved.
+func (o *Order) Trail(price int64) bool {
+	offset := o.TrailAmount
+	if offset == 0 {
+		// below 100% the offset is smaller than the price, it always fits
+		offset, _ = decimal.MulDiv(price, o.TrailPercentBps, 10000)
+	}
+
+	stop := price - offset
+	if o.Side == BUY {
+		stop = price + offset
+	}
+
+	if o.StopPrice != nil && (o.Side == SELL && stop <= *o.StopPrice || o.Side == BUY && stop >= *o.StopPrice) {
+		return false
+	}
+
+	o.StopPrice = &stop
+	return true
+}
+
+// IsPendingStop reports whether the order is a stop order waiting for its trigger
+func (o *Order) IsPendingStop() bool {
+	return o.IsStop() && o.Status == OPEN
+}
+
+// IsIceberg reports whether the order hides part of its quantity. An iceberg only
+// shows DisplayQuantity at a time, VisibleRemaining is what is left of the shown
+// slice and the rest of Remaining is the hidden reserve.
+func (o *Order) IsIceberg() bool {
+	return o.DisplayQuantity > 0
+}
+
+// Displayed returns the quantity shown in the order book, none for a hidden order
+func (o *Order) Displayed() int64 {
+	if o.Hidden {
+		return 0
+	}
+	return o.Tradable()
+}
+
+// Tradable returns the quantity the order can trade right away, an iceberg only trades
+// its shown slice
+func (o *Order) Tradable() int64 {
+	if o.IsIceberg() {
+		return o.VisibleRemaining
+	}
+	return o.Remaining
+}
+
+// Reserve returns the hidden quantity of an iceberg or a hidden order
+func (o *Order) Reserve() int64 {
+	return o.Remaining - o.Displayed()
+}
+
+// MinFill returns the least quantity a single match has to trade with the order, its
+// whole remainder for an all or none order, 0 when any quantity will do. A remainder
+// below MinQuantity trades in full.
+func (o *Order) MinFill() int64 {
+	if o.AllOrNone {
+		return o.Remaining
+	}
+	return min(o.MinQuantity, o.Remaining)
+}
+
+// Expired reports whether a DAY or GTD order is past its expiry
+func (o *Order) Expired(now time.Time) bool {
+	return o.ExpireAt != nil && !now.Before(*o.ExpireAt)
 }
 
 type Trade struct {
-	TradeID     int64     `json:"trade_id"`
-	Symbol      string    `json:"symbol"`
-	BuyOrderID  int64     `json:"buy_order_id"`
-	SellOrderID int64     `json:"sell_order_id"`
-	Quantity    int64     `json:"quantity"`
-	Price       int64     `json:"price"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	TradeID       int64     `json:"trade_id"`
+	Symbol        string    `json:"symbol"`
+	BuyOrderID    int64     `json:"buy_order_id"`
+	SellOrderID   int64     `json:"sell_order_id"`
+	Quantity      int64     `json:"quantity"`
+	Price         int64     `json:"price"`
+	Venue         Venue     `json:"venue"`
+	PriceScale    int       `json:"-"`
+	QuantityScale int       `json:"-"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// SelfTrade reports a trade between two orders of the same account that self-trade
+// prevention stopped, Quantity is what both orders were decremented by
+type SelfTrade struct {
+	OrderID        int64   `json:"order_id"`
+	RestingOrderID int64   `json:"resting_order_id"`
+	Mode           STPMode `json:"mode"`
+	Quantity       int64   `json:"quantity,omitempty"`
+	QuantityScale  int     `json:"-"`
 }
 
 type PlaceOrderRequest struct {
-	Symbol   string    `json:"symbol" validate:"required"`
-	Side     OrderSide `json:"side" validate:"required,oneof=buy sell"`
-	Type     OrderType `json:"type" validate:"required,oneof=limit market"`
-	Price    *int64    `json:"price,omitempty" validate:"required_if=Type limit,omitempty,gt=0"`
-	Quantity int64     `json:"quantity" validate:"required,gt=0"`
+	Symbol          string           `json:"symbol" validate:"required"`
+	AccountID       string           `json:"account_id,omitempty" validate:"omitempty,max=64"`
+	Side            OrderSide        `json:"side" validate:"required,oneof=buy sell"`
+	Type            OrderType        `json:"type" validate:"required,oneof=limit market stop stop_limit trailing_stop trailing_stop_limit pegged"`
+	Price           *decimal.Decimal `json:"price,omitempty" validate:"required_if=Type limit,required_if=Type stop_limit"`
+	StopPrice       *decimal.Decimal `json:"stop_price,omitempty" validate:"required_if=Type stop,required_if=Type stop_limit"`
+	Quantity        *decimal.Decimal `json:"quantity" validate:"required_without=QuoteQuantity"`
+	QuoteQuantity   *decimal.Decimal `json:"quote_quantity,omitempty" validate:"excluded_with=Quantity"`
+	DisplayQuantity *decimal.Decimal `json:"display_quantity,omitempty"`
+	PostOnly        bool             `json:"post_only,omitempty"`
+	Hidden          bool             `json:"hidden,omitempty" validate:"excluded_with=DisplayQuantity"`
+	Dark            bool             `json:"dark,omitempty"`
+	MinQuantity     *decimal.Decimal `json:"min_quantity,omitempty"`
+	AllOrNone       bool             `json:"all_or_none,omitempty" validate:"excluded_with=MinQuantity"`
+	STPMode         STPMode          `json:"stp_mode,omitempty" validate:"omitempty,oneof=cancel_newest cancel_oldest cancel_both decrement_cancel"`
+	MaxSlippageBps  int64            `json:"max_slippage_bps,omitempty" validate:"omitempty,gt=0,lt=10000"`
+	ProtectionPrice *decimal.Decimal `json:"protection_price,omitempty" validate:"excluded_with=MaxSlippageBps"`
+	TrailAmount     *decimal.Decimal `json:"trail_amount,omitempty"`
+	TrailPercent    *decimal.Decimal `json:"trail_percent,omitempty" validate:"excluded_with=TrailAmount"`
+	LimitOffset     *decimal.Decimal `json:"limit_offset,omitempty" validate:"required_if=Type trailing_stop_limit"`
+	PegType         PegType          `json:"peg_type,omitempty" validate:"required_if=Type pegged,omitempty,oneof=primary market midpoint"`
+	PegOffset       *decimal.Decimal `json:"peg_offset,omitempty"`
+	PegLimit        *decimal.Decimal `json:"peg_limit,omitempty"`
+	TimeInForce     TimeInForce      `json:"time_in_force,omitempty" validate:"omitempty,oneof=gtc ioc fok day gtd"`
+	ExpireAt        *time.Time       `json:"expire_at,omitempty" validate:"required_if=TimeInForce gtd"`
+
+	// bracket legs, opened by the order's fills
+	TakeProfit *TakeProfitRequest `json:"take_profit,omitempty"`
+	StopLoss   *StopLossRequest   `json:"stop_loss,omitempty"`
+}
+
+// TakeProfitRequest is the limit order closing a bracket's position at a profit
+type TakeProfitRequest struct {
+	Price *decimal.Decimal `json:"price" validate:"required"`
+}
+
+// StopLossRequest is the stop order closing a bracket's position at a loss, with a
+// price it is a stop limit order
+type StopLossRequest struct {
+	StopPrice *decimal.Decimal `json:"stop_price" validate:"required"`
+	Price     *decimal.Decimal `json:"price,omitempty"`
+}
+
+// AmendOrderRequest changes the price and/or the total quantity of a working order
+type AmendOrderRequest struct {
+	Price    *decimal.Decimal `json:"price,omitempty" validate:"required_without=Quantity"`
+	Quantity *decimal.Decimal `json:"quantity,omitempty" validate:"required_without=Price"`
+}
+
+// OrderGroup links orders of one symbol and account. The first order of a bracket is
+// its parent, the legs are created from the take-profit and stop-loss prices.
+type OrderGroup struct {
+	GroupID            int64           `json:"group_id"`
+	Type               GroupType       `json:"type"`
+	OnFill             GroupFillAction `json:"on_fill"`
+	TakeProfitPrice    *int64          `json:"take_profit_price,omitempty"`
+	StopLossPrice      *int64          `json:"stop_loss_price,omitempty"`
+	StopLossLimitPrice *int64          `json:"stop_loss_limit_price,omitempty"`
+	PriceScale         int             `json:"-"`
+	Orders             []*Order        `json:"orders"`
+	CreatedAt          time.Time       `json:"created_at"`
+}
+
+// PlaceOrderGroupRequest places linked orders of one symbol and account together
+type PlaceOrderGroupRequest struct {
+	Type   GroupType           `json:"type" validate:"required,oneof=oco"`
+	OnFill GroupFillAction     `json:"on_fill,omitempty" validate:"omitempty,oneof=cancel reduce"`
+	Orders []PlaceOrderRequest `json:"orders" validate:"len=2,dive"`
+}
+
+// SymbolStatus is the trading phase of a symbol, Until is when the phase ends by itself.
+// Mode is the phase the symbol trades in while open, continuous or batch.
+type SymbolStatus struct {
+	Symbol    string       `json:"symbol"`
+	Phase     TradingPhase `json:"phase"`
+	Mode      TradingPhase `json:"mode,omitempty"`
+	Reason    string       `json:"reason,omitempty"`
+	Until     *time.Time   `json:"until,omitempty"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+type HaltSymbolRequest struct {
+	Reason string `json:"reason,omitempty" validate:"max=255"`
+}
+
+// SetMatchingModeRequest switches a symbol between continuous and batch matching
+type SetMatchingModeRequest struct {
+	Mode TradingPhase `json:"mode" validate:"required,oneof=continuous batch"`
+}
+
+// Instrument is a tradable symbol. Prices are multiples of TickSize and quantities of
+// LotSize, PriceScale and QuantityScale are the number of decimals they are written
+// with. MaxQuantity 0 leaves the order size unbounded.
+type Instrument struct {
+	Symbol        string           `json:"symbol"`
+	BaseAsset     string           `json:"base_asset"`
+	QuoteAsset    string           `json:"quote_asset"`
+	TickSize      int64            `json:"tick_size"`
+	LotSize       int64            `json:"lot_size"`
+	MinQuantity   int64            `json:"min_quantity"`
+	MaxQuantity   int64            `json:"max_quantity"`
+	PriceScale    int              `json:"price_scale"`
+	QuantityScale int              `json:"quantity_scale"`
+	Status        InstrumentStatus `json:"status"`
+	CreatedAt     time.Time        `json:"created_at"`
+	UpdatedAt     time.Time        `json:"updated_at"`
+}
+
+// CreateInstrumentRequest lists a new instrument, the tick size defaults to the engine's
+// in units of the price scale, the lot size to the smallest quantity of the quantity scale
+type CreateInstrumentRequest struct {
+	Symbol        string           `json:"symbol" validate:"required,max=20"`
+	BaseAsset     string           `json:"base_asset" validate:"required,max=16"`
+	QuoteAsset    string           `json:"quote_asset" validate:"required,max=16"`
+	TickSize      *decimal.Decimal `json:"tick_size,omitempty"`
+	LotSize       *decimal.Decimal `json:"lot_size,omitempty"`
+	MinQuantity   *decimal.Decimal `json:"min_quantity,omitempty"`
+	MaxQuantity   *decimal.Decimal `json:"max_quantity,omitempty"`
+	PriceScale    int              `json:"price_scale,omitempty" validate:"gte=0,lte=18"`
+	QuantityScale int              `json:"quantity_scale,omitempty" validate:"gte=0,lte=18"`
+	Status        InstrumentStatus `json:"status,omitempty" validate:"omitempty,oneof=active inactive"`
+}
+
+// UpdateInstrumentRequest changes the settings of an instrument, its symbol and scales stay
+type UpdateInstrumentRequest struct {
+	BaseAsset   *string           `json:"base_asset,omitempty" validate:"omitempty,min=1,max=16"`
+	QuoteAsset  *string           `json:"quote_asset,omitempty" validate:"omitempty,min=1,max=16"`
+	TickSize    *decimal.Decimal  `json:"tick_size,omitempty"`
+	LotSize     *decimal.Decimal  `json:"lot_size,omitempty"`
+	MinQuantity *decimal.Decimal  `json:"min_quantity,omitempty"`
+	MaxQuantity *decimal.Decimal  `json:"max_quantity,omitempty"`
+	Status      *InstrumentStatus `json:"status,omitempty" validate:"omitempty,oneof=active inactive"`
 }
 
 type OrderBookEntry struct {
-	Price    int64 `json:"price"`
-	Quantity int64 `json:"quantity"`
+	Price    decimal.Decimal `json:"price"`
+	Quantity decimal.Decimal `json:"quantity"`
 }
 
 type OrderBookSnapshot struct {
-	Symbol string           `json:"symbol"`
-	Bids   []OrderBookEntry `json:"bids"`
-	Asks   []OrderBookEntry `json:"asks"`
+	Symbol  string           `json:"symbol"`
+	Bids    []OrderBookEntry `json:"bids"`
+	Asks    []OrderBookEntry `json:"asks"`
+	Auction *Auction         `json:"auction,omitempty"`
+}
+
+// Auction is the state of a symbol's auction call or batch, the indicative price is where the
+// book would uncross now. Imbalance is the quantity of ImbalanceSide left unmatched there.
+type Auction struct {
+	IndicativePrice  *int64    `json:"indicative_price"`
+	IndicativeVolume int64     `json:"indicative_volume"`
+	Imbalance        int64     `json:"imbalance"`
+	ImbalanceSide    OrderSide `json:"imbalance_side,omitempty"`
+	PriceScale       int       `json:"-"`
+	QuantityScale    int       `json:"-"`
 }